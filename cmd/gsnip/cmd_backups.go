@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "backups",
+			fn:      cmdBackups,
+			desc:    "list backups of the source file or restore one of them",
+			aliases: []string{"bak"},
+		},
+	)
+}
+
+func cmdBackups(args []string) error {
+	fs := flag.NewFlagSet("backups", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gsnip backups list|restore ID\n")
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		return transact(stream.Backups, []byte{})
+	case args[0] == "restore" && len(args) == 2:
+		return transact(stream.Restore, []byte(args[1]))
+	default:
+		fs.Usage()
+		return fmt.Errorf("backups expects list or restore ID")
+	}
+}
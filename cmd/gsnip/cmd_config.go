@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "config",
+			fn:      cmdConfig,
+			desc:    "show the effective configuration",
+			aliases: []string{"cfg"},
+		},
+	)
+}
+
+func cmdConfig(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gsnip config show\n")
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+	if args[0] != "show" || len(args) != 1 {
+		fs.Usage()
+		return fmt.Errorf("config expects show")
+	}
+	if cfgPath == "" {
+		fmt.Fprintln(os.Stdout, "# no configuration file")
+	} else {
+		fmt.Fprintf(os.Stdout, "# %s\n", cfgPath)
+	}
+	return cfg.Write(os.Stdout)
+}
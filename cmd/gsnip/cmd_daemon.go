@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mdm-code/gsnip/internal/fs"
+	"github.com/mdm-code/gsnip/internal/server"
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+// errNotRunning is raised when no server listens on the socket.
+var errNotRunning = errors.New("gsnipd is not running")
+
+// daemonTimeout bounds the time spent waiting for the server to start or stop.
+const daemonTimeout = 10 * time.Second
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "daemon",
+			fn:      cmdDaemon,
+			desc:    "show the status of gsnipd, stop or restart it",
+			aliases: []string{"dm"},
+		},
+	)
+}
+
+func cmdDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gsnip daemon status|stop|restart\n")
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if direct != "" {
+		return fmt.Errorf("daemon manages gsnipd and cannot be used with -file")
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+	if len(args) != 1 {
+		fs.Usage()
+		return fmt.Errorf("daemon expects status, stop or restart")
+	}
+	switch args[0] {
+	case "status":
+		return daemonStatus()
+	case "stop":
+		return stopDaemon()
+	case "restart":
+		return restartDaemon()
+	default:
+		fs.Usage()
+		return fmt.Errorf("daemon expects status, stop or restart")
+	}
+}
+
+// daemonStatus reports whether the server answers on the socket and which
+// process holds its PID file, followed by the status of a running server.
+func daemonStatus() error {
+	pid, running, err := fs.ReadPID(server.PIDPath(cfg.Sock))
+	if err != nil {
+		return err
+	}
+	alive := server.Alive(cfg.Sock)
+	switch {
+	case alive && running:
+		fmt.Fprintf(os.Stdout, "gsnipd is running (pid %d) on %s\n", pid, cfg.Sock)
+	case alive:
+		fmt.Fprintf(os.Stdout, "gsnipd is running on %s\n", cfg.Sock)
+	case running:
+		return fmt.Errorf("gsnipd (pid %d) does not answer on %s", pid, cfg.Sock)
+	default:
+		return fmt.Errorf("%w on %s", errNotRunning, cfg.Sock)
+	}
+	return printStatus()
+}
+
+// stopDaemon asks the server to shut down and waits until it is gone. A
+// server that does not answer is sent SIGTERM if it holds the PID file.
+func stopDaemon() error {
+	path := server.PIDPath(cfg.Sock)
+	pid, running, err := fs.ReadPID(path)
+	if err != nil {
+		return err
+	}
+	if _, err := deliver(stream.Request{Operation: stream.Shutdown}, false); err != nil {
+		if !running {
+			return fmt.Errorf("%w on %s", errNotRunning, cfg.Sock)
+		}
+		p, err := os.FindProcess(pid)
+		if err != nil {
+			return err
+		}
+		if err := p.Signal(syscall.SIGTERM); err != nil {
+			return fmt.Errorf("could not stop process %d: %w", pid, err)
+		}
+	}
+	deadline := time.Now().Add(daemonTimeout)
+	for {
+		_, running, err := fs.ReadPID(path)
+		if err != nil {
+			return err
+		}
+		if !running && !server.Alive(cfg.Sock) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("gsnipd did not stop within %s", daemonTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// restartDaemon stops the server and starts it again with the command line
+// it was started with, or with the configuration if it is not running.
+func restartDaemon() error {
+	saved, err := fs.ReadCmdline(server.PIDPath(cfg.Sock))
+	if err != nil {
+		return err
+	}
+	if err := stopDaemon(); err != nil && !errors.Is(err, errNotRunning) {
+		return err
+	}
+	return startDaemon(saved)
+}
+
+// startDaemon starts gsnipd in the background and waits until it accepts
+// connections. The server is started with the saved command line or, if it
+// is empty, on the configured socket with the configured source file. Its
+// messages are appended to a log file kept next to the socket.
+func startDaemon(saved fs.Cmdline) error {
+	prog, err := daemonPath()
+	if err != nil {
+		return err
+	}
+	logPath := strings.TrimSuffix(cfg.Sock, ".sock") + ".log"
+	if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
+		return err
+	}
+	log, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+
+	args := []string{"-sock", cfg.Sock}
+	if cfg.File != "" {
+		args = append(args, "-file", cfg.File)
+	}
+	if saved.Args != nil {
+		args = saved.Args
+	}
+	c := exec.Command(prog, args...)
+	c.Dir = saved.Dir
+	c.Stdout, c.Stderr = log, log
+	detach(c)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- c.Wait() }()
+	if err := server.AwaitStart(cfg.Sock, exited, daemonTimeout); err != nil {
+		return fmt.Errorf("gsnipd: %w, see %s", err, logPath)
+	}
+	return nil
+}
+
+// daemonPath finds gsnipd next to the gsnip executable or on the $PATH.
+func daemonPath() (string, error) {
+	if exe, err := os.Executable(); err == nil {
+		prog := filepath.Join(filepath.Dir(exe), "gsnipd")
+		if _, err := os.Stat(prog); err == nil {
+			return prog, nil
+		}
+	}
+	return exec.LookPath("gsnipd")
+}
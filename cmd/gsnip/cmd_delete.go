@@ -3,8 +3,10 @@ package main
 import (
 	"bufio"
 	"flag"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/mdm-code/gsnip/internal/stream"
 )
@@ -14,7 +16,7 @@ func init() {
 		cmd{
 			name:    "delete",
 			fn:      cmdDel,
-			desc:    "delete a snippet",
+			desc:    "delete snippets",
 			aliases: []string{"d", "del"},
 		},
 	)
@@ -22,6 +24,7 @@ func init() {
 
 func cmdDel(args []string) error {
 	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	rev := fs.String("rev", "", "delete only if the snippet is at this revision")
 	err := fs.Parse(args)
 	if err != nil {
 		return err
@@ -36,11 +39,20 @@ func cmdDel(args []string) error {
 			names = append(names, s.Text())
 		}
 	}
-	for _, name := range names {
-		err := transact(stream.Delete, []byte(name))
-		if err != nil && err != io.EOF {
-			return err
-		}
+	if *rev != "" && len(names) != 1 {
+		return fmt.Errorf("-rev applies to a single snippet")
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	reply, err := send(stream.Request{
+		Operation: stream.Delete,
+		Body:      []byte(strings.Join(names, " ")),
+		Rev:       *rev,
+	})
+	if err != nil && err != io.EOF {
+		return err
 	}
+	fmt.Fprintf(os.Stdout, "%s\n", reply.Body)
 	return nil
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mdm-code/gsnip/internal/editor"
+	"github.com/mdm-code/gsnip/internal/parsing"
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "edit",
+			fn:      cmdEdit,
+			desc:    "edit a snippet in $EDITOR",
+			aliases: []string{"e", "ed"},
+		},
+	)
+}
+
+func cmdEdit(args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) != 1 {
+		return fmt.Errorf("edit expects a single snippet name")
+	}
+	name := args[0]
+
+	snips, err := dump()
+	if err != nil {
+		return err
+	}
+	var found bool
+	var rev, repr string
+	for _, s := range snips {
+		if s.Name == name {
+			found, rev, repr = true, s.Rev(), s.Repr()
+		}
+	}
+	if !found {
+		return fmt.Errorf("snippet was not found: %s", name)
+	}
+
+	e, err := editor.NewProgramEditor(nil, cfg.Editor)
+	if err != nil {
+		return err
+	}
+	defer e.Exit()
+	if _, err := e.Write([]byte(repr)); err != nil {
+		return err
+	}
+	data, err := e.Run()
+	if err != nil {
+		return err
+	}
+	if string(data) == repr {
+		return nil
+	}
+
+	parser := parsing.NewParser()
+	container, err := parser.Parse(strings.NewReader(string(data)))
+	if err != nil {
+		return keep(data, err)
+	}
+	if _, err := container.Find(name); err != nil {
+		return keep(data, fmt.Errorf("the edited snippet must keep its name: %s", name))
+	}
+
+	_, err = send(stream.Request{Operation: stream.Update, Body: data, Rev: rev})
+	var serr *stream.Error
+	if errors.As(err, &serr) && serr.Code == stream.Conflict {
+		return keep(data, fmt.Errorf("%w; the snippet was changed by someone else", err))
+	}
+	return err
+}
+
+// keep saves the edited text so that it is not lost when the update fails.
+func keep(data []byte, cause error) error {
+	f, err := os.CreateTemp("", "gsnip-edit-*.snip")
+	if err != nil {
+		return cause
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return cause
+	}
+	return fmt.Errorf("%w\nyour changes were saved to %s", cause, f.Name())
+}
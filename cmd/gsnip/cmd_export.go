@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mdm-code/gsnip/internal/convert"
+	"github.com/mdm-code/gsnip/internal/parsing"
+	"github.com/mdm-code/gsnip/internal/snippets"
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "export",
+			fn:      cmdExport,
+			desc:    "export snippets to another format",
+			aliases: []string{"ex", "exp"},
+		},
+	)
+}
+
+func cmdExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	to := fs.String(
+		"to",
+		cfg.Output,
+		"format of the exported snippets: vscode, ultisnips, json, yaml or markdown",
+	)
+	lang := fs.String("lang", "", "export only snippets with the lang attribute")
+	tag := fs.String("tag", "", "export only snippets with the tag in the tags attribute")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("export takes no arguments")
+	}
+
+	exp, err := convert.NewExporter(*to)
+	if err != nil {
+		return err
+	}
+	snips, err := dump()
+	if err != nil {
+		return err
+	}
+	return exp.Export(os.Stdout, convert.Filter(snips, *lang, *tag))
+}
+
+// dump fetches all snippets stored on the server.
+func dump() ([]snippets.Snippet, error) {
+	reply, err := call(stream.Dump, []byte{})
+	if err != nil {
+		return nil, err
+	}
+	parser := parsing.NewParser()
+	container, err := parser.Parse(strings.NewReader(string(reply.Body)))
+	if errors.Is(err, parsing.ErrEmptyFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return container.ListObj()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "history",
+			fn:      cmdHistory,
+			desc:    "list recent changes or show the change with the given ID",
+			aliases: []string{"hist"},
+		},
+	)
+}
+
+func cmdHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) > 1 {
+		return fmt.Errorf("history expects at most one change ID")
+	}
+	var id string
+	if len(args) == 1 {
+		id = args[0]
+	}
+	return transact(stream.History, []byte(id))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mdm-code/gsnip/internal/convert"
+	"github.com/mdm-code/gsnip/internal/snippets"
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "import",
+			fn:      cmdImport,
+			desc:    "import snippets from another format",
+			aliases: []string{"im", "imp"},
+		},
+	)
+}
+
+func cmdImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := fs.String("from", "", "format of the imported file: vscode, ultisnips or snipmate")
+	conflict := fs.String(
+		"conflict",
+		"skip",
+		"on name collision: skip, overwrite or rename the imported snippet",
+	)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) != 1 {
+		return fmt.Errorf("import expects a single file")
+	}
+	if *conflict != "skip" && *conflict != "overwrite" && *conflict != "rename" {
+		return fmt.Errorf("unknown conflict resolution: %s", *conflict)
+	}
+
+	imp, err := convert.NewImporter(*from)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	result, err := imp.Import(f)
+	if err != nil {
+		return err
+	}
+	for _, w := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "gsnip WARNING: %s\n", w)
+	}
+
+	existing, err := names()
+	if err != nil {
+		return err
+	}
+	snips := resolve(result.Snippets, existing, *conflict)
+	if len(snips) == 0 {
+		return nil
+	}
+
+	var data strings.Builder
+	for _, s := range snips {
+		data.WriteString(s.Repr())
+	}
+	op := stream.Insert
+	if *conflict == "overwrite" {
+		op = stream.Update
+	}
+	_, err = call(op, []byte(data.String()))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "imported %d snippets\n", len(snips))
+	return nil
+}
+
+// resolve reports and settles collisions between names of imported snippets
+// and names already taken on the server or earlier in the batch.
+func resolve(snips []snippets.Snippet, taken map[string]bool, policy string) []snippets.Snippet {
+	var result []snippets.Snippet
+	batch := make(map[string]bool)
+	for _, s := range snips {
+		if !taken[s.Name] && !batch[s.Name] {
+			batch[s.Name] = true
+			result = append(result, s)
+			continue
+		}
+		switch {
+		case policy == "rename":
+			name := s.Name
+			for i := 2; taken[s.Name] || batch[s.Name]; i++ {
+				s.Name = fmt.Sprintf("%s-%d", name, i)
+			}
+			fmt.Fprintf(os.Stderr, "gsnip: %s exists: renamed to %s\n", name, s.Name)
+		case policy == "overwrite" && !batch[s.Name]:
+			fmt.Fprintf(os.Stderr, "gsnip: %s exists: overwritten\n", s.Name)
+		default:
+			fmt.Fprintf(os.Stderr, "gsnip: %s exists: skipped\n", s.Name)
+			continue
+		}
+		batch[s.Name] = true
+		result = append(result, s)
+	}
+	return result
+}
@@ -48,7 +48,7 @@ func insert() (string, error) {
 
 		return strings.Join(lines, "\n"), nil
 	}
-	e, err := editor.NewEditor(nil)
+	e, err := editor.NewProgramEditor(nil, cfg.Editor)
 	if err != nil {
 		return "", err
 	}
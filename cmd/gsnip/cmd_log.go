@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "log",
+			fn:      cmdLog,
+			desc:    "list commits changing a snippet",
+			aliases: []string{"lg"},
+		},
+	)
+}
+
+func cmdLog(args []string) error {
+	fs := flag.NewFlagSet("log", flag.ContinueOnError)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) != 1 {
+		return fmt.Errorf("log expects a single snippet name")
+	}
+	return transact(stream.Log, []byte(args[0]))
+}
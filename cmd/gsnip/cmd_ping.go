@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "ping",
+			fn:      cmdPing,
+			desc:    "check that gsnipd answers requests without starting it",
+			aliases: []string{"p"},
+		},
+	)
+}
+
+func cmdPing(args []string) error {
+	fs := flag.NewFlagSet("ping", flag.ContinueOnError)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	reply, err := deliver(stream.Request{Operation: stream.Ping, Body: []byte{}}, false)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("%w on %s", errNotRunning, cfg.Sock)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", reply.Body)
+	return nil
+}
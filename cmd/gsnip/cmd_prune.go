@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "prune",
+			fn:      cmdPrune,
+			desc:    "delete snippets not used for a while",
+			aliases: []string{"pr"},
+		},
+	)
+}
+
+func cmdPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	since := fs.String(
+		"unused-since",
+		"180d",
+		"time window with no use, e.g. 180d, 8w or 720h",
+	)
+	maxHits := fs.Int(
+		"max-hits",
+		0,
+		"also delete snippets found no more than this many times in total",
+	)
+	dryRun := fs.Bool("dry-run", false, "list the snippets without deleting them")
+	yes := fs.Bool("yes", false, "delete without asking for confirmation")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("prune takes no arguments")
+	}
+	window, err := parseWindow(*since)
+	if err != nil {
+		return err
+	}
+
+	if *maxHits < 0 {
+		return fmt.Errorf("bad number of hits: %d", *maxHits)
+	}
+
+	reply, err := call(stream.Unused, []byte(fmt.Sprintf("%s %d", window, *maxHits)))
+	if err != nil {
+		return err
+	}
+	var names, revs []string
+	for _, l := range strings.Split(string(reply.Body), "\n") {
+		f := strings.Split(l, "\t")
+		if len(f) < 4 {
+			continue
+		}
+		names = append(names, f[0])
+		revs = append(revs, f[3])
+		fmt.Fprintln(os.Stdout, l)
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(os.Stdout, "no snippets unused for %s\n", *since)
+		return nil
+	}
+	if *dryRun {
+		return nil
+	}
+	if !*yes && !confirm(fmt.Sprintf("Delete %d snippets?", len(names))) {
+		return nil
+	}
+	// NOTE: Snippets changed since they were listed are not deleted
+	_, err = send(stream.Request{
+		Operation: stream.Delete,
+		Body:      []byte(strings.Join(names, " ")),
+		Rev:       strings.Join(revs, " "),
+	})
+	var serr *stream.Error
+	if errors.As(err, &serr) && serr.Code == stream.Conflict {
+		return fmt.Errorf("%w; run prune again", err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "deleted %d snippets\n", len(names))
+	return nil
+}
+
+// parseWindow parses durations like 180d and 8w on top of the units
+// accepted by time.ParseDuration.
+func parseWindow(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			if v, err := strconv.Atoi(n); err == nil && v > 0 {
+				return time.Duration(v) * unit, nil
+			}
+			return 0, fmt.Errorf("bad time window: %s", s)
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("bad time window: %s", s)
+	}
+	return d, nil
+}
+
+// confirm asks the question on the terminal and reports whether the answer
+// was yes.
+func confirm(question string) bool {
+	in := os.Stdin
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		in = tty
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "redo",
+			fn:      cmdRedo,
+			desc:    "reapply the most recently undone change",
+			aliases: []string{"rd"},
+		},
+	)
+}
+
+func cmdRedo(args []string) error {
+	fs := flag.NewFlagSet("redo", flag.ContinueOnError)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("redo takes no arguments")
+	}
+	return transact(stream.Redo, []byte{})
+}
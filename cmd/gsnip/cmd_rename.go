@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "rename",
+			fn:      cmdRename,
+			desc:    "rename a snippet keeping its aliases",
+			aliases: []string{"mv"},
+		},
+	)
+}
+
+func cmdRename(args []string) error {
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	rev := fs.String("rev", "", "rename only if the snippet is at this revision")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) != 2 {
+		return fmt.Errorf("rename expects the old and the new name")
+	}
+	_, err = send(stream.Request{
+		Operation: stream.Rename,
+		Body:      []byte(args[0] + " " + args[1]),
+		Rev:       *rev,
+	})
+	return err
+}
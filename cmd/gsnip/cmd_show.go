@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "show",
+			fn:      cmdShow,
+			desc:    "show a snippet at a commit given as NAME@REV",
+			aliases: []string{"sh"},
+		},
+	)
+}
+
+func cmdShow(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) != 1 {
+		return fmt.Errorf("show expects a single NAME@REV argument")
+	}
+	return transact(stream.Show, []byte(args[0]))
+}
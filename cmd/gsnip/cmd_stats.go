@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "stats",
+			fn:      cmdStats,
+			desc:    "list snippet use counts, last use and frecency, or server metrics",
+			aliases: []string{"st"},
+		},
+	)
+}
+
+func cmdStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	server := fs.Bool("server", false, "print the metrics of the server instead")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *server {
+		return transact(stream.Stats, []byte{})
+	}
+	return transact(stream.Usage, []byte{})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+	"github.com/mdm-code/gsnip/internal/version"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "status",
+			fn:      cmdStatus,
+			desc:    "show the version, uptime, source files and snippet count of gsnipd",
+			aliases: []string{"version"},
+		},
+	)
+}
+
+func cmdStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	return printStatus()
+}
+
+// printStatus prints the versions of the client and the server along with
+// the status of the server.
+func printStatus() error {
+	fmt.Fprintf(os.Stdout, "%-14s %s (protocol %d)\n", "client:", version.String(), stream.Protocol)
+	reply, err := deliver(stream.Request{Operation: stream.Version, Body: []byte{}}, false)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("%w on %s", errNotRunning, cfg.Sock)
+	}
+	if err != nil {
+		return err
+	}
+	var st stream.Status
+	if err := json.Unmarshal(reply.Body, &st); err != nil {
+		return fmt.Errorf("could not read server status: %w", err)
+	}
+	reload := "ok"
+	if st.ReloadError != "" {
+		reload = "failed: " + st.ReloadError
+	}
+	fmt.Fprintf(os.Stdout, "%-14s %s (protocol %d)\n", "server:", st.Version, st.Protocol)
+	fmt.Fprintf(os.Stdout, "%-14s %s\n", "uptime:", st.Uptime.Round(time.Second))
+	fmt.Fprintf(os.Stdout, "%-14s %s\n", "source files:", strings.Join(st.Files, ", "))
+	fmt.Fprintf(os.Stdout, "%-14s %d\n", "snippets:", st.Snippets)
+	fmt.Fprintf(os.Stdout, "%-14s %s (%s)\n", "last reload:", st.Reloaded.Format(time.DateTime), reload)
+	return nil
+}
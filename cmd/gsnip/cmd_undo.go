@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+func init() {
+	addCmd(
+		cmd{
+			name:    "undo",
+			fn:      cmdUndo,
+			desc:    "revert the most recent change",
+			aliases: []string{"u"},
+		},
+	)
+}
+
+func cmdUndo(args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("undo takes no arguments")
+	}
+	return transact(stream.Undo, []byte{})
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// detach leaves the command as it is on platforms without sessions.
+func detach(c *exec.Cmd) {}
@@ -1,17 +1,41 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net/rpc"
 	"net/rpc/jsonrpc"
 	"os"
+	"os/user"
 	"strings"
 
+	"github.com/mdm-code/gsnip/internal/config"
+	"github.com/mdm-code/gsnip/internal/fs"
+	"github.com/mdm-code/gsnip/internal/server"
 	"github.com/mdm-code/gsnip/internal/stream"
+	"github.com/mdm-code/gsnip/internal/version"
 )
 
-var sock string
+// cfg is the effective configuration: flags override GSNIP_* environment
+// variables, which override the configuration file found at cfgPath.
+var (
+	cfg     config.Config
+	cfgPath string
+)
+
+// direct names the source file requests are executed against in-process,
+// without gsnipd.
+var direct string
+
+// mismatch is set once the client has warned that the server speaks another
+// protocol version.
+var mismatch bool
+
+// errOutdated is raised when the server does not understand the handshake
+// the client opens connections with.
+var errOutdated = errors.New("gsnipd is older than gsnip")
 
 var cmdList []cmd
 
@@ -57,8 +81,26 @@ func main() {
 }
 
 func parseArgs() ([]string, error) {
+	var err error
+	cfg, cfgPath, err = config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gsnip ERROR: %s\n", err)
+		return nil, err
+	}
 	fs := flag.NewFlagSet("gsnip", flag.ContinueOnError)
-	fs.StringVar(&sock, "sock", "/tmp/gsnip.sock", "UDS server socket name")
+	fs.StringVar(&cfg.Sock, "sock", cfg.Sock, "UDS server socket name")
+	fs.StringVar(
+		&direct,
+		"file",
+		"",
+		"execute commands against the source file in-process, without gsnipd",
+	)
+	fs.BoolVar(
+		&cfg.Autostart,
+		"autostart",
+		cfg.Autostart,
+		"start gsnipd in the background when it is not running",
+	)
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Global options:\n")
@@ -69,7 +111,7 @@ func parseArgs() ([]string, error) {
 		}
 	}
 
-	err := fs.Parse(os.Args[1:])
+	err = fs.Parse(os.Args[1:])
 	if err != nil {
 		return nil, err
 	}
@@ -92,27 +134,138 @@ func dispatchCmd(args []string) error {
 }
 
 func transact(op stream.Opcode, data []byte) error {
-	conn, err := jsonrpc.Dial("unix", sock)
+	reply, err := call(op, data)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
+	fmt.Fprintf(os.Stdout, "%s\n", reply.Body)
+	return nil
+}
+
+// call sends the request to the server and returns its reply. Failures
+// reported by the server are returned as *stream.Error.
+func call(op stream.Opcode, data []byte) (stream.Reply, error) {
+	return send(stream.Request{Operation: op, Body: data})
+}
 
-	request := stream.Request{Operation: op, Body: data}
+// send sends the request on behalf of the current user. The server is started
+// first if it is not running and autostart is enabled.
+func send(request stream.Request) (stream.Reply, error) {
+	return deliver(request, cfg.Autostart)
+}
+
+// deliver sends the request on behalf of the current user. With spawn set,
+// gsnipd is started when no server listens on the socket. With the -file
+// option, the request is executed in-process instead.
+func deliver(request stream.Request, spawn bool) (stream.Reply, error) {
 	var reply stream.Reply
+	if direct != "" {
+		return execute(request)
+	}
+	// NOTE: A socket in a directory other users can write to may belong to
+	// a server impersonating gsnipd
+	if err := server.CheckDir(cfg.Sock); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return reply, err
+	}
+	var start func() error
+	if spawn {
+		start = func() error { return startDaemon(fs.Cmdline{}) }
+	}
+	nc, err := server.Dial(cfg.Sock, start)
+	if err != nil {
+		return reply, err
+	}
+	conn := jsonrpc.NewClient(nc)
+	defer conn.Close()
+
+	welcome, err := handshake(conn)
+	if err != nil {
+		return reply, err
+	}
+	if !welcome.Supports(request.Operation) {
+		return reply, &stream.Error{
+			Code: stream.Unsupported,
+			Message: fmt.Sprintf(
+				"gsnipd %s does not support %s; enable it in the gsnipd configuration, or upgrade gsnipd and restart it with gsnip daemon restart",
+				welcome.Version,
+				request.Operation,
+			),
+		}
+	}
+
+	request.Protocol = stream.Protocol
+	request.User = username()
 
 	err = conn.Call("Manager.Execute", request, &reply)
 	if err != nil {
-		return err
+		return reply, err
 	}
+	return reply, reply.Err()
+}
 
-	if reply.Result == stream.Failure {
-		fmt.Fprintf(os.Stderr, "%s\n", "ERROR")
-		return nil
+// handshake learns the protocol version and the capabilities of the server
+// before the first request is sent over the connection. It warns when the
+// server speaks another protocol version.
+func handshake(conn *rpc.Client) (stream.Welcome, error) {
+	var welcome stream.Welcome
+	hello := stream.Hello{Protocol: stream.Protocol, Version: version.String()}
+	err := conn.Call("Manager.Hello", hello, &welcome)
+	var serr rpc.ServerError
+	if errors.As(err, &serr) && strings.HasPrefix(string(serr), "rpc: can't find method") {
+		return welcome, fmt.Errorf(
+			"%w: it does not speak protocol %d; restart it with gsnip daemon restart",
+			errOutdated,
+			stream.Protocol,
+		)
 	}
+	if err != nil {
+		return welcome, err
+	}
+	if welcome.Protocol != stream.Protocol && !mismatch {
+		mismatch = true
+		fmt.Fprintf(
+			os.Stderr,
+			"gsnip WARNING: gsnipd speaks protocol %d, gsnip speaks %d; restart gsnipd with gsnip daemon restart\n",
+			welcome.Protocol,
+			stream.Protocol,
+		)
+	}
+	return welcome, nil
+}
 
-	fmt.Fprintf(os.Stdout, "%s\n", reply.Body)
-	return nil
+// execute runs the request against the source file set with -file the same
+// way gsnipd would.
+func execute(request stream.Request) (stream.Reply, error) {
+	var reply stream.Reply
+	request.User = username()
+	// NOTE: Failures are carried in the reply as they are by gsnipd
+	if err := server.ExecuteFile(cfg, direct, request, &reply); err != nil && reply.Result != stream.Failure {
+		return reply, err
+	}
+	return reply, reply.Err()
+}
+
+// username names the user running the client.
+func username() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// names lists out the names of snippets stored on the server.
+func names() (map[string]bool, error) {
+	reply, err := call(stream.List, []byte{})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool)
+	for _, l := range strings.Split(string(reply.Body), "\n") {
+		if name, _, _ := strings.Cut(l, "\t"); name != "" {
+			result[name] = true
+		}
+	}
+	return result, nil
 }
 
 func isPiped() bool {
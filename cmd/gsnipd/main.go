@@ -1,32 +1,143 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
+	"github.com/mdm-code/gsnip/internal/config"
+	"github.com/mdm-code/gsnip/internal/fs"
+	"github.com/mdm-code/gsnip/internal/logging"
+	"github.com/mdm-code/gsnip/internal/manager"
 	"github.com/mdm-code/gsnip/internal/server"
 	"github.com/mdm-code/xdg"
 )
 
-var (
-	sock string
-	file string
-)
+// cfg is the effective configuration: flags override GSNIP_* environment
+// variables, which override the configuration file.
+var cfg config.Config
+
+// replace makes gsnipd stop the server running on the same socket instead of
+// refusing to start.
+var replace bool
+
+// replaceTimeout bounds the time spent waiting for the replaced server to stop.
+const replaceTimeout = 10 * time.Second
 
 func main() {
+	var cfgPath string
+	var err error
+	cfg, cfgPath, err = config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gsnipd ERROR: %s\n", err)
+		os.Exit(1)
+	}
 	flag.StringVar(
-		&sock,
+		&cfg.Sock,
 		"sock",
-		"/tmp/gsnip.sock",
+		cfg.Sock,
 		"UDS server socket name",
 	)
-	flag.StringVar(&file, "file", "", "snippet source file")
+	flag.StringVar(&cfg.File, "file", cfg.File, "snippet source file")
+	flag.StringVar(
+		&cfg.Format,
+		"format",
+		cfg.Format,
+		"snippet source file format: gsnip, json or yaml (default by file extension)",
+	)
+	flag.IntVar(
+		&cfg.History,
+		"history",
+		cfg.History,
+		"number of mutations kept for undo and redo (0 disables history)",
+	)
+	flag.IntVar(
+		&cfg.Backups,
+		"backups",
+		cfg.Backups,
+		"number of source file backups kept (0 disables backups)",
+	)
+	flag.StringVar(
+		&cfg.BackupDir,
+		"backup-dir",
+		cfg.BackupDir,
+		"backup directory (default <file>.backups)",
+	)
+	flag.DurationVar(
+		&cfg.BackupAge,
+		"backup-age",
+		cfg.BackupAge,
+		"age after which backups are pruned (0 keeps them regardless of age)",
+	)
+	flag.BoolVar(
+		&cfg.Git,
+		"git",
+		cfg.Git,
+		"commit the source file to its git repository after each change",
+	)
+	flag.BoolVar(
+		&cfg.Stats,
+		"stats",
+		cfg.Stats,
+		"record snippet usage in <file>.stats and list snippets by frecency",
+	)
+	flag.StringVar(
+		&cfg.LogLevel,
+		"log-level",
+		cfg.LogLevel,
+		"lowest severity of logged messages: debug, info, warn or error",
+	)
+	flag.StringVar(
+		&cfg.LogFormat,
+		"log-format",
+		cfg.LogFormat,
+		"format of logged messages: text or json",
+	)
+	flag.StringVar(
+		&cfg.LogFile,
+		"log-file",
+		cfg.LogFile,
+		"file messages are logged to (default stderr)",
+	)
+	flag.IntVar(
+		&cfg.LogSize,
+		"log-max-size",
+		cfg.LogSize,
+		"size in megabytes after which the log file is rotated (0 disables rotation)",
+	)
+	flag.IntVar(
+		&cfg.LogKeep,
+		"log-keep",
+		cfg.LogKeep,
+		"number of rotated log files kept",
+	)
+	flag.DurationVar(
+		&cfg.Idle,
+		"idle-timeout",
+		cfg.Idle,
+		"stop after no connection was made for the duration (0 keeps running)",
+	)
+	flag.StringVar(
+		&cfg.Metrics,
+		"metrics-addr",
+		cfg.Metrics,
+		"serve Prometheus metrics over HTTP on the loopback address, e.g. 127.0.0.1:9464",
+	)
+	flag.BoolVar(
+		&replace,
+		"replace",
+		false,
+		"stop the server running on the same socket and take its place",
+	)
 	setupFlags(flag.CommandLine)
 	flag.Parse()
 
+	file := cfg.File
 	if file == "" {
 		var ok bool
 		file, ok = xdg.Find(xdg.Data, "gsnip/snippets")
@@ -36,28 +147,139 @@ func main() {
 		}
 	}
 
-	cleanup()
-	defer cleanup()
+	logger, closer, err := logging.New(logging.Options{
+		Level:   cfg.LogLevel,
+		Format:  cfg.LogFormat,
+		File:    cfg.LogFile,
+		MaxSize: int64(cfg.LogSize) << 20,
+		Keep:    cfg.LogKeep,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gsnipd ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	defer closer.Close()
 
-	s, err := server.NewServer("unix", sock, file)
-	defer s.ShutDown()
+	pid, err := claim()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gsnipd ERROR: %s\n", err)
+		os.Exit(2)
+	}
+	defer pid.Remove()
 
+	opts, err := manager.Configure(cfg, file)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "gsnipd ERROR: %s", err)
+		fmt.Fprintf(os.Stderr, "gsnipd ERROR: %s\n", err)
 		os.Exit(1)
 	}
 
-	s.Log("INFO", fmt.Sprintf("reading source file: %s", file))
+	inherited, err := server.Activated()
+	if err == nil && len(inherited) > 1 {
+		err = fmt.Errorf("expected a single socket, systemd passed %d", len(inherited))
+	}
+	if err == nil && len(inherited) == 0 {
+		err = server.Prepare(cfg.Sock)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gsnipd ERROR: %s\n", err)
+		os.Exit(2)
+	}
+
+	s, err := server.NewServer("unix", cfg.Sock, file, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gsnipd ERROR: %s\n", err)
+		os.Exit(1)
+	}
+	defer s.ShutDown()
+	s.SetLogger(logger)
+
+	if len(inherited) == 1 {
+		s.Inherit(inherited[0])
+	}
+	s.SetIdleTimeout(cfg.Idle)
+
+	if cfgPath != "" {
+		logger.Info("read configuration file", "path", cfgPath)
+	}
+	logger.Info("reading source file", "path", file)
 	err = s.Listen()
 	if err != nil {
-		s.Log(
-			"ERROR",
-			fmt.Sprintf("UDS socket file taken: %s", sock),
-		)
+		logger.Error("could not listen", "sock", cfg.Sock, "error", err)
 		os.Exit(2)
 	}
+	if cfg.Metrics != "" {
+		if err := s.ServeMetrics(cfg.Metrics); err != nil {
+			logger.Error("could not serve metrics", "addr", cfg.Metrics, "error", err)
+			os.Exit(2)
+		}
+	}
 	s.AwaitSignal(syscall.SIGHUP)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-stop
+		s.Stop()
+	}()
 	s.AwaitConn()
+	logger.Info("stopped")
+}
+
+// claim checks that the socket directory is safe to use and takes the PID
+// file kept in it, recording the command line so that gsnip daemon restart
+// starts the server again the same way.
+func claim() (*fs.PIDFile, error) {
+	// NOTE: The directory is checked before the PID file is written so that
+	// no file planted there by another user is ever written to
+	if err := os.MkdirAll(filepath.Dir(cfg.Sock), 0700); err != nil {
+		return nil, err
+	}
+	if err := server.CheckDir(cfg.Sock); err != nil {
+		return nil, err
+	}
+	pid, err := fs.CreatePID(server.PIDPath(cfg.Sock))
+	if errors.Is(err, fs.ErrLocked) && replace {
+		pid, err = takeOver(server.PIDPath(cfg.Sock))
+	} else if errors.Is(err, fs.ErrLocked) {
+		err = fmt.Errorf("%w on %s (%s); use -replace to take its place", server.ErrRunning, cfg.Sock, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.Getwd()
+	if err == nil {
+		err = pid.Record(fs.Cmdline{Dir: dir, Args: os.Args[1:]})
+	}
+	if err != nil {
+		pid.Remove()
+		return nil, err
+	}
+	return pid, nil
+}
+
+// takeOver asks the server holding the PID file to stop and takes the file
+// over once the server is gone.
+func takeOver(path string) (*fs.PIDFile, error) {
+	pid, running, err := fs.ReadPID(path)
+	if err != nil {
+		return nil, err
+	}
+	if running {
+		p, err := os.FindProcess(pid)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Signal(syscall.SIGTERM); err != nil {
+			return nil, fmt.Errorf("could not stop process %d: %w", pid, err)
+		}
+	}
+	deadline := time.Now().Add(replaceTimeout)
+	for {
+		f, err := fs.CreatePID(path)
+		if !errors.Is(err, fs.ErrLocked) || time.Now().After(deadline) {
+			return f, err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
 }
 
 func setupFlags(f *flag.FlagSet) {
@@ -67,11 +289,3 @@ func setupFlags(f *flag.FlagSet) {
 		f.PrintDefaults()
 	}
 }
-
-func cleanup() {
-	if _, err := os.Stat(sock); err == nil {
-		if err := os.RemoveAll(sock); err != nil {
-			log.Fatal(err)
-		}
-	}
-}
@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoBackup is raised when the requested backup does not exist.
+var ErrNoBackup = errors.New("no such backup")
+
+const (
+	idLayout = "20060102T150405.000000"
+	suffix   = ".bak"
+)
+
+// Backup describes a single copy of the source file.
+type Backup struct {
+	ID   string
+	Time time.Time
+	Size int64
+}
+
+// Store keeps timestamped copies of the source file in a directory. Copies
+// beyond the Keep most recent ones or older than MaxAge are pruned whenever a
+// new copy is saved; zero disables the respective limit. The most recent copy
+// is never pruned.
+type Store struct {
+	Dir    string
+	Keep   int
+	MaxAge time.Duration
+	base   string
+	now    func() time.Time
+}
+
+// NewStore creates a backup store for the source file fname. An empty dir
+// puts the backups in the <fname>.backups directory.
+func NewStore(dir, fname string, keep int, maxAge time.Duration) *Store {
+	if dir == "" {
+		dir = fname + ".backups"
+	}
+	return &Store{
+		Dir:    dir,
+		Keep:   keep,
+		MaxAge: maxAge,
+		base:   filepath.Base(fname),
+		now:    time.Now,
+	}
+}
+
+// Save writes a new copy of the source file contents and prunes old copies.
+func (s *Store) Save(data []byte) (Backup, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return Backup{}, err
+	}
+	t := s.now().UTC()
+	b := Backup{ID: t.Format(idLayout), Time: t, Size: int64(len(data))}
+	if err := os.WriteFile(s.path(b.ID), data, 0644); err != nil {
+		return Backup{}, err
+	}
+	return b, s.prune(t)
+}
+
+// List lists the stored copies starting from the most recent one.
+func (s *Store) List() ([]Backup, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result []Backup
+	for _, e := range entries {
+		id, ok := s.id(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		t, err := time.Parse(idLayout, id)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Backup{ID: id, Time: t, Size: info.Size()})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	return result, nil
+}
+
+// Load reads the contents of the copy with the given ID.
+func (s *Store) Load(id string) ([]byte, error) {
+	if _, err := time.Parse(idLayout, id); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoBackup, id)
+	}
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoBackup, id)
+	}
+	return data, err
+}
+
+// prune removes copies exceeding the count and age limits.
+func (s *Store) prune(now time.Time) error {
+	backups, err := s.List()
+	if err != nil {
+		return err
+	}
+	for i, b := range backups {
+		if i == 0 {
+			continue
+		}
+		tooMany := s.Keep > 0 && i >= s.Keep
+		tooOld := s.MaxAge > 0 && now.Sub(b.Time) > s.MaxAge
+		if tooMany || tooOld {
+			if err := os.Remove(s.path(b.ID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.Dir, s.base+"."+id+suffix)
+}
+
+// id extracts the backup ID from the name of a file in the store directory.
+func (s *Store) id(fname string) (string, bool) {
+	prefix := s.base + "."
+	if !strings.HasPrefix(fname, prefix) || !strings.HasSuffix(fname, suffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(fname, prefix), suffix), true
+}
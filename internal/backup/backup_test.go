@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// clock returns a function reporting consecutive minutes from start.
+func clock(start time.Time) func() time.Time {
+	t := start
+	return func() time.Time {
+		t = t.Add(time.Minute)
+		return t
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	s := NewStore(t.TempDir(), "/home/user/snippets", 0, 0)
+	b, err := s.Save([]byte("contents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err := s.Load(b.ID)
+	if err != nil || string(has) != "contents" {
+		t.Errorf("has: %q (%v)", has, err)
+	}
+	if _, err := os.Stat(filepath.Join(s.Dir, "snippets."+b.ID+".bak")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDefaultDir(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "snippets")
+	s := NewStore("", fname, 0, 0)
+	if s.Dir != fname+".backups" {
+		t.Errorf("has: %s", s.Dir)
+	}
+}
+
+func TestLoadFails(t *testing.T) {
+	s := NewStore(t.TempDir(), "snippets", 0, 0)
+	for _, id := range []string{"20200101T000000.000000", "../../etc/passwd", ""} {
+		if _, err := s.Load(id); !errors.Is(err, ErrNoBackup) {
+			t.Errorf("%q: want: %v; has: %v", id, ErrNoBackup, err)
+		}
+	}
+}
+
+func TestPruneByCount(t *testing.T) {
+	s := NewStore(t.TempDir(), "snippets", 3, 0)
+	s.now = clock(time.Now())
+	var last Backup
+	for i := 0; i < 5; i++ {
+		last, _ = s.Save([]byte{byte(i)})
+	}
+	backups, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 || backups[0].ID != last.ID {
+		t.Errorf("want 3 backups starting with %s; has: %v", last.ID, backups)
+	}
+}
+
+func TestPruneByAge(t *testing.T) {
+	s := NewStore(t.TempDir(), "snippets", 0, 90*time.Second)
+	s.now = clock(time.Now())
+	for i := 0; i < 4; i++ {
+		s.Save([]byte{byte(i)})
+	}
+	backups, _ := s.List()
+	if len(backups) != 2 {
+		t.Errorf("want 2 backups; has: %v", backups)
+	}
+}
+
+func TestPruneKeepsNewest(t *testing.T) {
+	s := NewStore(t.TempDir(), "snippets", 0, time.Nanosecond)
+	s.now = clock(time.Now())
+	s.Save([]byte("a"))
+	s.Save([]byte("b"))
+	backups, _ := s.List()
+	if len(backups) != 1 || backups[0].Size != 1 {
+		t.Errorf("want the newest backup; has: %v", backups)
+	}
+}
+
+func TestListIgnoresForeignFiles(t *testing.T) {
+	s := NewStore(t.TempDir(), "snippets", 0, 0)
+	os.WriteFile(filepath.Join(s.Dir, "other.20200101T000000.000000.bak"), nil, 0644)
+	os.WriteFile(filepath.Join(s.Dir, "snippets.notes.bak"), nil, 0644)
+	s.Save([]byte("x"))
+	backups, _ := s.List()
+	if len(backups) != 1 {
+		t.Errorf("want 1 backup; has: %v", backups)
+	}
+}
+
+func TestListMissingDir(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "missing"), "snippets", 0, 0)
+	if backups, err := s.List(); err != nil || len(backups) != 0 {
+		t.Errorf("has: %v (%v)", backups, err)
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mdm-code/xdg"
+)
+
+// Path is the location of the configuration file relative to the XDG
+// configuration directories.
+const Path = "gsnip/config"
+
+// ErrKey is raised when the configuration refers to an unknown key or holds a
+// value of the wrong type.
+var ErrKey = errors.New("invalid configuration")
+
+// Config holds the settings shared by gsnipd and gsnip.
+type Config struct {
+	Sock      string
+	File      string
+	Format    string
+	History   int
+	Backups   int
+	BackupDir string
+	BackupAge time.Duration
+	Git       bool
+	Stats     bool
+	LogLevel  string
+	LogFormat string
+	LogFile   string
+	LogSize   int
+	LogKeep   int
+	Editor    string
+	Output    string
+	Autostart bool
+	Idle      time.Duration
+	Metrics   string
+}
+
+// field binds a configuration key to the Config field holding its value.
+type field struct {
+	key string
+	ptr interface{}
+}
+
+// fields lists the keys of the configuration in the order they are shown.
+func (c *Config) fields() []field {
+	return []field{
+		{"sock", &c.Sock},
+		{"file", &c.File},
+		{"format", &c.Format},
+		{"history", &c.History},
+		{"backups", &c.Backups},
+		{"backup_dir", &c.BackupDir},
+		{"backup_age", &c.BackupAge},
+		{"git", &c.Git},
+		{"stats", &c.Stats},
+		{"log_level", &c.LogLevel},
+		{"log_format", &c.LogFormat},
+		{"log_file", &c.LogFile},
+		{"log_max_size", &c.LogSize},
+		{"log_keep", &c.LogKeep},
+		{"editor", &c.Editor},
+		{"output", &c.Output},
+		{"autostart", &c.Autostart},
+		{"idle_timeout", &c.Idle},
+		{"metrics_addr", &c.Metrics},
+	}
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() Config {
+	return Config{
+		Sock:      DefaultSock(),
+		History:   100,
+		Backups:   10,
+		BackupAge: 30 * 24 * time.Hour,
+		Stats:     true,
+		LogLevel:  "info",
+		LogFormat: "text",
+		LogSize:   10,
+		LogKeep:   3,
+		Editor:    os.Getenv("EDITOR"),
+	}
+}
+
+// DefaultSock returns the per-user socket path: gsnip/gsnip.sock under
+// $XDG_RUNTIME_DIR, or under a gsnip-UID directory in the temporary directory
+// when the variable is not set.
+func DefaultSock() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "gsnip", "gsnip.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gsnip-%d", os.Getuid()), "gsnip.sock")
+}
+
+// Load returns the default configuration overridden by the configuration file
+// found in the XDG configuration directories and then by GSNIP_* environment
+// variables. It also returns the path of the file, which is empty if there is
+// none.
+func Load() (Config, string, error) {
+	c := Default()
+	path, ok := xdg.Find(xdg.Config, Path)
+	if ok {
+		f, err := os.Open(path)
+		if err != nil {
+			return c, path, err
+		}
+		defer f.Close()
+		if err := c.Parse(f); err != nil {
+			return c, path, fmt.Errorf("%s: %w", path, err)
+		}
+	} else {
+		path = ""
+	}
+	return c, path, c.Env(os.LookupEnv)
+}
+
+// Parse reads `key = value` lines into the configuration. Empty lines and
+// lines starting with # are skipped.
+func (c *Config) Parse(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%w: line %d: expected key = value", ErrKey, n)
+		}
+		if err := c.Set(strings.TrimSpace(key), unquote(strings.TrimSpace(value))); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return s.Err()
+}
+
+// Env overrides the configuration with GSNIP_<KEY> variables, e.g. GSNIP_SOCK
+// or GSNIP_LOG_LEVEL, returned by lookup.
+func (c *Config) Env(lookup func(string) (string, bool)) error {
+	for _, f := range c.fields() {
+		name := "GSNIP_" + strings.ToUpper(f.key)
+		if v, ok := lookup(name); ok {
+			if err := c.Set(f.key, v); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Set assigns the value to the configuration key.
+func (c *Config) Set(key, value string) error {
+	for _, f := range c.fields() {
+		if f.key != key {
+			continue
+		}
+		var err error
+		switch p := f.ptr.(type) {
+		case *string:
+			*p = value
+		case *int:
+			*p, err = strconv.Atoi(value)
+		case *bool:
+			*p, err = strconv.ParseBool(value)
+		case *time.Duration:
+			*p, err = time.ParseDuration(value)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %q", ErrKey, key, value)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown key %q", ErrKey, key)
+}
+
+// Write prints the configuration in the format read by Parse.
+func (c *Config) Write(w io.Writer) error {
+	for _, f := range c.fields() {
+		var v interface{}
+		switch p := f.ptr.(type) {
+		case *string:
+			v = strconv.Quote(*p)
+		case *int:
+			v = *p
+		case *bool:
+			v = *p
+		case *time.Duration:
+			v = *p
+		}
+		if _, err := fmt.Fprintf(w, "%s = %v\n", f.key, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unquote strips double quotes from quoted values.
+func unquote(s string) string {
+	if v, err := strconv.Unquote(s); err == nil && strings.HasPrefix(s, `"`) {
+		return v
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	c := Default()
+	src := `
+# shared by gsnipd and gsnip
+sock = /run/user/1000/gsnip.sock
+file = "~/snips/my snippets"
+history=5
+git = true
+backup_age = 48h
+`
+	if err := c.Parse(strings.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.Sock = "/run/user/1000/gsnip.sock"
+	want.File = "~/snips/my snippets"
+	want.History = 5
+	want.Git = true
+	want.BackupAge = 48 * time.Hour
+	if c != want {
+		t.Errorf("want: %+v; has: %+v", want, c)
+	}
+}
+
+func TestParseFail(t *testing.T) {
+	data := []struct {
+		name string
+		src  string
+	}{
+		{"unknown key", "colour = red"},
+		{"no value", "sock"},
+		{"not a number", "history = many"},
+		{"not a bool", "git = maybe"},
+		{"not a duration", "backup_age = 30"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			c := Default()
+			if err := c.Parse(strings.NewReader(d.src)); !errors.Is(err, ErrKey) {
+				t.Errorf("want: %v; has: %v", ErrKey, err)
+			}
+		})
+	}
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	c := Default()
+	c.Parse(strings.NewReader("sock = /from/file\nlog_level = error\n"))
+	env := map[string]string{"GSNIP_SOCK": "/from/env", "GSNIP_STATS": "false"}
+	err := c.Env(func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Sock != "/from/env" || c.Stats || c.LogLevel != "error" {
+		t.Errorf("has: %+v", c)
+	}
+	env["GSNIP_HISTORY"] = "lots"
+	if err := c.Env(func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}); !errors.Is(err, ErrKey) {
+		t.Errorf("want: %v; has: %v", ErrKey, err)
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	c := Default()
+	c.Editor = "code --wait"
+	c.Output = "vscode"
+	var b bytes.Buffer
+	if err := c.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	has := Config{}
+	if err := has.Parse(&b); err != nil {
+		t.Fatal(err)
+	}
+	if has != c {
+		t.Errorf("want: %+v; has: %+v", c, has)
+	}
+}
+
+func TestDefaultSock(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if has := DefaultSock(); has != "/run/user/1000/gsnip/gsnip.sock" {
+		t.Errorf("has: %s", has)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if has := DefaultSock(); !strings.HasSuffix(has, "/gsnip.sock") || strings.HasPrefix(has, "/run") {
+		t.Errorf("has: %s", has)
+	}
+}
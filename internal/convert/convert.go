@@ -0,0 +1,62 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+// Result holds snippets converted from a foreign format along with warnings
+// about the constructs that could not be carried over.
+type Result struct {
+	Snippets []snippets.Snippet
+	Warnings []string
+}
+
+// Importer converts snippets stored in a foreign format.
+type Importer interface {
+	Import(io.Reader) (Result, error)
+}
+
+// NewImporter creates an importer for the given format.
+//
+// Allowed formats (f): vscode, ultisnips, snipmate
+func NewImporter(f string) (Importer, error) {
+	switch f {
+	case "vscode":
+		return vscodeImporter{}, nil
+	case "ultisnips":
+		return ultisnipsImporter{}, nil
+	case "snipmate":
+		return snipmateImporter{}, nil
+	default:
+		return nil, fmt.Errorf("import format (%s) is not implemented", f)
+	}
+}
+
+// warnf appends a formatted warning to the result.
+func (r *Result) warnf(format string, a ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, a...))
+}
+
+// add appends the snippet to the result unless it breaks the naming rules, in
+// which case a warning is recorded instead.
+func (r *Result) add(s snippets.Snippet) {
+	if err := snippets.DefaultValidator.Validate(s); err != nil {
+		r.warnf("skipped: %s", err)
+		return
+	}
+	r.Snippets = append(r.Snippets, s)
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// sanitizeName turns a foreign trigger into a valid snippet name by replacing
+// runs of white space with dashes and dropping double quotes.
+func sanitizeName(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), `"`, "")
+	return whitespace.ReplaceAllString(s, "-")
+}
@@ -0,0 +1,86 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mdm-code/gsnip/internal/parsing"
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+// ErrUnexportable is raised when a snippet cannot be written out in the
+// export format.
+var ErrUnexportable = errors.New("snippet cannot be exported")
+
+// Exporter writes snippets out in a foreign format.
+type Exporter interface {
+	Export(io.Writer, []snippets.Snippet) error
+}
+
+// NewExporter creates an exporter for the given format.
+//
+// Allowed formats (f): vscode, ultisnips, json, yaml, markdown
+func NewExporter(f string) (Exporter, error) {
+	switch f {
+	case "vscode":
+		return vscodeExporter{}, nil
+	case "ultisnips":
+		return ultisnipsExporter{}, nil
+	case "json", "yaml":
+		f, err := parsing.NewFormat(f)
+		return formatExporter{f}, err
+	case "markdown":
+		return markdownExporter{}, nil
+	default:
+		return nil, fmt.Errorf("export format (%s) is not implemented", f)
+	}
+}
+
+// Filter keeps snippets whose lang attribute lists lang and whose tags
+// attribute lists tag. Attribute values are separated with commas or white
+// space. Empty lang or tag match every snippet.
+func Filter(snips []snippets.Snippet, lang, tag string) []snippets.Snippet {
+	var result []snippets.Snippet
+	for _, s := range snips {
+		if hasValue(s.Attrs["lang"], lang) && hasValue(s.Attrs["tags"], tag) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func hasValue(attr, value string) bool {
+	if value == "" {
+		return true
+	}
+	return contains(splitList(attr), value)
+}
+
+// splitList splits the attribute value on commas and white space.
+func splitList(attr string) []string {
+	return strings.FieldsFunc(attr, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// formatExporter writes snippets out in one of the structured source file
+// formats.
+type formatExporter struct {
+	f parsing.Format
+}
+
+func (e formatExporter) Export(w io.Writer, snips []snippets.Snippet) error {
+	return e.f.Write(w, snips)
+}
+
+// encodeJSON writes out the indented value without escaping HTML characters,
+// which are common in snippet bodies.
+func encodeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,184 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+var exported = []snippets.Snippet{
+	{
+		Name:  "for",
+		Desc:  "for loop",
+		Body:  "for ${1:i} := 0; ${1} < ${2:n}; ${1}++ {\n\t${0}\n}",
+		Attrs: map[string]string{"lang": "go", "tags": "loops,basics", "aliases": "loop,fori"},
+	},
+	{
+		Name:  "html",
+		Desc:  "page <template> | with `ticks`",
+		Body:  "<p>```</p>\n",
+		Attrs: map[string]string{"options": "b"},
+	},
+}
+
+func TestExportVSCodeRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	exp, _ := NewExporter("vscode")
+	if err := exp.Export(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	imp, _ := NewImporter("vscode")
+	has, err := imp.Import(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []snippets.Snippet{
+		{Name: "for", Desc: "for loop", Body: exported[0].Body, Attrs: map[string]string{"lang": "go", "aliases": "loop,fori"}},
+		{Name: "html", Desc: exported[1].Desc, Body: exported[1].Body},
+	}
+	if !reflect.DeepEqual(has.Snippets, want) {
+		t.Errorf("want: %v; has: %v", want, has.Snippets)
+	}
+}
+
+func TestExportUltiSnipsRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	exp, _ := NewExporter("ultisnips")
+	if err := exp.Export(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	imp, _ := NewImporter("ultisnips")
+	has, err := imp.Import(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []snippets.Snippet{
+		{Name: "for", Desc: "for loop", Body: exported[0].Body},
+		exported[1],
+	}
+	if !reflect.DeepEqual(has.Snippets, want) {
+		t.Errorf("want: %v; has: %v", want, has.Snippets)
+	}
+}
+
+func TestExportUltiSnipsEscapes(t *testing.T) {
+	var buf bytes.Buffer
+	exp, _ := NewExporter("ultisnips")
+	snips := []snippets.Snippet{{Name: "q", Desc: "say \"hi\"\ntwice", Body: "  endsnippet"}}
+	if err := exp.Export(&buf, snips); err != nil {
+		t.Fatal(err)
+	}
+	imp, _ := NewImporter("ultisnips")
+	has, err := imp.Import(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []snippets.Snippet{{Name: "q", Desc: "say 'hi' twice", Body: "  endsnippet"}}
+	if !reflect.DeepEqual(has.Snippets, want) || len(has.Warnings) != 0 {
+		t.Errorf("want: %v; has: %v %q", want, has.Snippets, has.Warnings)
+	}
+
+	buf.Reset()
+	snips = append(snips, snippets.Snippet{Name: "end", Body: "a\nendsnippet b"})
+	if err := exp.Export(&buf, snips); !errors.Is(err, ErrUnexportable) || buf.Len() != 0 {
+		t.Errorf("want: %v and no output; has: %v %q", ErrUnexportable, err, buf.String())
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	exp, _ := NewExporter("json")
+	if err := exp.Export(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<template>") {
+		t.Errorf("HTML characters should not be escaped: %s", buf.String())
+	}
+	var has []snippets.Snippet
+	if err := json.Unmarshal(buf.Bytes(), &has); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(has, exported) {
+		t.Errorf("want: %v; has: %v", exported, has)
+	}
+}
+
+func TestExportYAML(t *testing.T) {
+	var buf bytes.Buffer
+	exp, _ := NewExporter("yaml")
+	if err := exp.Export(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	want := `- name: "for"
+  desc: "for loop"
+  attrs:
+    "aliases": "loop,fori"
+    "lang": "go"
+    "tags": "loops,basics"
+  body: |-
+    for ${1:i} := 0; ${1} < ${2:n}; ${1}++ {
+    	${0}
+    }
+- name: "html"
+  desc: "page <template> | with ` + "`ticks`" + `"
+  attrs:
+    "options": "b"
+  body: |
+    <p>` + "```" + `</p>
+`
+	if buf.String() != want {
+		t.Errorf("want:\n%s\nhas:\n%s", want, buf.String())
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	exp, _ := NewExporter("markdown")
+	if err := exp.Export(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	has := buf.String()
+	for _, want := range []string{
+		"| `for` | for loop |\n",
+		"| `html` | page <template> \\| with `ticks` |\n",
+		"```go\nfor ${1:i}",
+		"````\n<p>```</p>\n\n````\n",
+		"- tags: `loops,basics`\n",
+	} {
+		if !strings.Contains(has, want) {
+			t.Errorf("want %q in:\n%s", want, has)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	data := []struct {
+		name, lang, tag string
+		want            int
+	}{
+		{"all", "", "", 2},
+		{"lang", "go", "", 1},
+		{"tag", "", "basics", 1},
+		{"both", "go", "loops", 1},
+		{"none", "go", "html", 0},
+		{"partial word", "g", "", 0},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			if has := Filter(exported, d.lang, d.tag); len(has) != d.want {
+				t.Errorf("want: %d; has: %v", d.want, has)
+			}
+		})
+	}
+}
+
+func TestNewExporterFails(t *testing.T) {
+	if _, err := NewExporter("sublime"); err == nil {
+		t.Error("expected an error caused by unknown format")
+	}
+}
@@ -0,0 +1,80 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+// markdownExporter writes snippets out as a browsable Markdown catalog: an
+// index table followed by a section with the fenced body of each snippet.
+type markdownExporter struct{}
+
+func (markdownExporter) Export(w io.Writer, snips []snippets.Snippet) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Snippets\n\n")
+	bw.WriteString("| Name | Description |\n")
+	bw.WriteString("| ---- | ----------- |\n")
+	for _, s := range snips {
+		fmt.Fprintf(bw, "| `%s` | %s |\n", s.Name, markdownCell(s.Desc))
+	}
+	for _, s := range snips {
+		fmt.Fprintf(bw, "\n## %s\n\n", s.Name)
+		if s.Desc != "" {
+			fmt.Fprintf(bw, "%s\n\n", s.Desc)
+		}
+		if len(s.Attrs) > 0 {
+			keys := make([]string, 0, len(s.Attrs))
+			for k := range s.Attrs {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(bw, "- %s: `%s`\n", k, s.Attrs[k])
+			}
+			bw.WriteString("\n")
+		}
+		fence := markdownFence(s.Body)
+		fmt.Fprintf(bw, "%s%s\n%s\n%s\n", fence, firstLang(s.Attrs["lang"]), s.Body, fence)
+	}
+	return bw.Flush()
+}
+
+// markdownCell keeps the text within a single table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return whitespace.ReplaceAllString(s, " ")
+}
+
+// markdownFence returns a code fence longer than any run of backticks found
+// in the body.
+func markdownFence(body string) string {
+	longest, run := 0, 0
+	for _, r := range body {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		longest = 2
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// firstLang picks the first language listed in the lang attribute for the
+// info string of the code fence.
+func firstLang(attr string) string {
+	if langs := splitList(attr); len(langs) > 0 {
+		return langs[0]
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package convert
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+// snipmateImporter reads SnipMate .snippets files where snippet bodies are
+// indented with a single tab.
+type snipmateImporter struct{}
+
+// Import converts the SnipMate snippets. The text following the trigger on
+// the snippet line becomes the description.
+func (snipmateImporter) Import(r io.Reader) (Result, error) {
+	var result Result
+	var current *snippets.Snippet
+	var body []string
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		for len(body) > 0 && body[len(body)-1] == "" {
+			body = body[:len(body)-1]
+		}
+		current.Body = strings.Join(body, "\n")
+		result.add(*current)
+		current, body = nil, nil
+	}
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if current != nil && (strings.HasPrefix(line, "\t") || line == "") {
+			body = append(body, strings.TrimPrefix(line, "\t"))
+			continue
+		}
+		flush()
+
+		word := firstWord(line)
+		switch {
+		case strings.HasPrefix(line, "snippet ") || strings.HasPrefix(line, "snippet\t"):
+			rest := strings.TrimSpace(line[len("snippet"):])
+			trigger, desc := rest, ""
+			if i := strings.IndexAny(rest, " \t"); i >= 0 {
+				trigger, desc = rest[:i], strings.TrimSpace(rest[i:])
+			}
+			current = &snippets.Snippet{Name: sanitizeName(trigger), Desc: desc}
+		case word == "extends" || word == "priority" || word == "version" || word == "delete":
+			result.warnf("line %d: ignored %s", n, strings.TrimSpace(line))
+		case word == "" || strings.HasPrefix(word, "#"):
+		default:
+			result.warnf("line %d: ignored unknown line: %s", n, line)
+		}
+	}
+	flush()
+	return result, s.Err()
+}
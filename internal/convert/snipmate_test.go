@@ -0,0 +1,54 @@
+package convert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+const snipmateFile = "extends html\n" +
+	"# for loops\n" +
+	"snippet for for loop with an index\n" +
+	"\tfor ${1:i} := 0; $1 < ${2:n}; $1++ {\n" +
+	"\t\t${3}\n" +
+	"\n" +
+	"\t}\n" +
+	"\n" +
+	"snippet fn\n" +
+	"\tfunc ${1:name}() {}\n" +
+	"\n\n" +
+	"priority -10\n" +
+	"snippet @LST reserved\n" +
+	"\tbody\n"
+
+func TestSnipMateImport(t *testing.T) {
+	imp, err := NewImporter("snipmate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err := imp.Import(strings.NewReader(snipmateFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []snippets.Snippet{
+		{
+			Name: "for",
+			Desc: "for loop with an index",
+			Body: "for ${1:i} := 0; $1 < ${2:n}; $1++ {\n\t${3}\n\n}",
+		},
+		{
+			Name: "fn",
+			Desc: "",
+			Body: "func ${1:name}() {}",
+		},
+	}
+	if !reflect.DeepEqual(has.Snippets, want) {
+		t.Errorf("want: %v; has: %v", want, has.Snippets)
+	}
+	// NOTE: extends, priority and the reserved name
+	if len(has.Warnings) != 3 {
+		t.Errorf("want three warnings; has: %q", has.Warnings)
+	}
+}
@@ -0,0 +1,168 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+// ultisnipsImporter reads UltiSnips .snippets files.
+type ultisnipsImporter struct{}
+
+// ultisnipsDirectives are the top-level lines that have no gsnip equivalent.
+var ultisnipsDirectives = []string{
+	"priority",
+	"extends",
+	"clearsnippets",
+	"context",
+	"pre_expand",
+	"post_expand",
+	"post_jump",
+}
+
+// Import converts the UltiSnips snippets. Snippet options other than the ones
+// changing how the trigger is matched are kept in the options attribute.
+func (ultisnipsImporter) Import(r io.Reader) (Result, error) {
+	var result Result
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		word := firstWord(line)
+		switch {
+		case word == "snippet":
+			start := n
+			var body []string
+			closed := false
+			for s.Scan() {
+				n++
+				if strings.HasPrefix(s.Text(), "endsnippet") {
+					closed = true
+					break
+				}
+				body = append(body, s.Text())
+			}
+			if !closed {
+				result.warnf("line %d: snippet is missing endsnippet", start)
+				break
+			}
+			ultisnipsSnippet(&result, start, line, strings.Join(body, "\n"))
+		case word == "global":
+			start := n
+			for s.Scan() {
+				n++
+				if strings.HasPrefix(s.Text(), "endglobal") {
+					break
+				}
+			}
+			result.warnf("line %d: skipped global code block", start)
+		case contains(ultisnipsDirectives, word):
+			result.warnf("line %d: ignored %s", n, strings.TrimSpace(line))
+		case word == "" || strings.HasPrefix(word, "#"):
+		default:
+			result.warnf("line %d: ignored unknown line: %s", n, line)
+		}
+	}
+	return result, s.Err()
+}
+
+// ultisnipsSnippet splits the header line of the snippet following the rules
+// of UltiSnips: the options are the last word following the quoted
+// description, and the trigger is whatever remains.
+func ultisnipsSnippet(result *Result, n int, header, body string) {
+	remain := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(header), "snippet"))
+	var desc, opts string
+
+	words := strings.Fields(remain)
+	if len(words) > 2 && strings.HasSuffix(words[len(words)-2], `"`) {
+		opts = words[len(words)-1]
+		remain = strings.TrimSpace(remain[:len(remain)-len(opts)])
+	}
+	words = strings.Fields(remain)
+	if len(words) > 1 && strings.HasSuffix(remain, `"`) {
+		if i := strings.LastIndex(remain[:len(remain)-1], `"`); i >= 0 {
+			desc = remain[i+1 : len(remain)-1]
+			remain = strings.TrimSpace(remain[:i])
+		}
+	}
+
+	trigger := remain
+	if len(strings.Fields(trigger)) > 1 || strings.Contains(opts, "r") {
+		if len(trigger) < 2 || trigger[0] != trigger[len(trigger)-1] {
+			result.warnf("line %d: skipped snippet with invalid multiword trigger: %s", n, trigger)
+			return
+		}
+		trigger = trigger[1 : len(trigger)-1]
+	}
+	if strings.Contains(opts, "r") {
+		result.warnf("line %d: skipped snippet with regular expression trigger: %s", n, trigger)
+		return
+	}
+	if strings.Contains(opts, "e") {
+		result.warnf("line %d: %s: ignored custom context", n, trigger)
+		opts = strings.ReplaceAll(opts, "e", "")
+	}
+	if strings.Contains(body, "`!") {
+		result.warnf("line %d: %s: body contains code interpolation", n, trigger)
+	}
+
+	snip := snippets.Snippet{Name: sanitizeName(trigger), Desc: desc, Body: body}
+	if opts != "" {
+		snip.Attrs = map[string]string{"options": opts}
+	}
+	result.add(snip)
+}
+
+func firstWord(line string) string {
+	if f := strings.Fields(line); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ultisnipsExporter writes snippets out as an UltiSnips .snippets file. The
+// options attribute is written out as snippet options.
+type ultisnipsExporter struct{}
+
+// Export fails without writing anything if a snippet body has a line
+// starting with endsnippet, which UltiSnips would take for the end of the
+// snippet.
+func (ultisnipsExporter) Export(w io.Writer, snips []snippets.Snippet) error {
+	for _, s := range snips {
+		for _, l := range strings.Split(s.Body, "\n") {
+			if strings.HasPrefix(l, "endsnippet") {
+				return fmt.Errorf("%w: %s: body line starts with endsnippet", ErrUnexportable, s.Name)
+			}
+		}
+	}
+	for _, s := range snips {
+		header := "snippet " + s.Name + " \"" + ultisnipsDesc(s.Desc) + "\""
+		if opts := s.Attrs["options"]; opts != "" {
+			header += " " + opts
+		}
+		_, err := fmt.Fprintf(w, "%s\n%s\nendsnippet\n\n", header, s.Body)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ultisnipsDesc makes the description fit on the header line. UltiSnips has
+// no escapes and ends the description at its last double quote, so double
+// quotes become single quotes and line breaks become spaces.
+func ultisnipsDesc(desc string) string {
+	desc = strings.ReplaceAll(desc, `"`, "'")
+	return strings.Join(strings.Fields(desc), " ")
+}
@@ -0,0 +1,88 @@
+package convert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+const ultisnipsFile = `priority -50
+extends c, cpp
+
+# A comment
+global !p
+def helper():
+	return 1
+endglobal
+
+snippet for "for loop" b
+for ${1:i} := 0; $1 < ${2:n}; $1++ {
+	${VISUAL}$0
+}
+endsnippet
+
+snippet !if err! "error check"
+if err != nil {
+	return err
+}
+endsnippet
+
+snippet iferr
+if err != nil {}
+endsnippet
+
+snippet "re(\w+)" "regex trigger" r
+` + "`!p snip.rv = match.group(1)`" + `
+endsnippet
+
+snippet unterminated "never closed"
+body`
+
+func TestUltiSnipsImport(t *testing.T) {
+	imp, err := NewImporter("ultisnips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err := imp.Import(strings.NewReader(ultisnipsFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []snippets.Snippet{
+		{
+			Name:  "for",
+			Desc:  "for loop",
+			Body:  "for ${1:i} := 0; $1 < ${2:n}; $1++ {\n\t${VISUAL}$0\n}",
+			Attrs: map[string]string{"options": "b"},
+		},
+		{
+			Name: "if-err",
+			Desc: "error check",
+			Body: "if err != nil {\n\treturn err\n}",
+		},
+		{
+			Name: "iferr",
+			Desc: "",
+			Body: "if err != nil {}",
+		},
+	}
+	if !reflect.DeepEqual(has.Snippets, want) {
+		t.Errorf("want: %v; has: %v", want, has.Snippets)
+	}
+	// NOTE: priority, extends, global, regex trigger and missing endsnippet
+	if len(has.Warnings) != 5 {
+		t.Errorf("want five warnings; has: %q", has.Warnings)
+	}
+}
+
+func TestUltiSnipsInvalidTrigger(t *testing.T) {
+	imp, _ := NewImporter("ultisnips")
+	has, err := imp.Import(strings.NewReader("snippet !two words# \"desc\"\nbody\nendsnippet"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(has.Snippets) != 0 || len(has.Warnings) != 1 {
+		t.Errorf("want the snippet skipped; has: %v %q", has.Snippets, has.Warnings)
+	}
+}
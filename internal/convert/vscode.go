@@ -0,0 +1,245 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+// vscodeImporter reads VS Code snippet files: both the global
+// .code-snippets files and the language-specific JSON files.
+type vscodeImporter struct{}
+
+// stringList decodes a JSON value that is either a string or an array of
+// strings.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = stringList{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(data, &ss); err != nil {
+		return fmt.Errorf("expected a string or an array of strings: %s", data)
+	}
+	*l = ss
+	return nil
+}
+
+type vscodeSnippet struct {
+	Prefix      stringList `json:"prefix"`
+	Body        stringList `json:"body"`
+	Description stringList `json:"description"`
+	Scope       string     `json:"scope,omitempty"`
+}
+
+// Import converts the VS Code snippets. The first prefix becomes the snippet
+// name and the other ones become its aliases. The scope, if any, is kept in
+// the lang attribute.
+func (vscodeImporter) Import(r io.Reader) (Result, error) {
+	var result Result
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return result, err
+	}
+	var file map[string]vscodeSnippet
+	if err := json.Unmarshal(stripJSONC(data), &file); err != nil {
+		return result, fmt.Errorf("malformed VS Code snippet file: %w", err)
+	}
+
+	keys := make([]string, 0, len(file))
+	for k := range file {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := file[k]
+		name := k
+		if len(v.Prefix) > 0 {
+			name = v.Prefix[0]
+		}
+		var aliases []string
+		if len(v.Prefix) > 1 {
+			for _, a := range v.Prefix[1:] {
+				a = strings.ReplaceAll(sanitizeName(a), ",", "-")
+				if a != "" && a != sanitizeName(name) {
+					aliases = append(aliases, a)
+				}
+			}
+		}
+		desc := strings.Join(v.Description, " ")
+		if desc == "" {
+			desc = k
+		}
+		s := snippets.Snippet{
+			Name: sanitizeName(name),
+			Desc: desc,
+			Body: vscodePlaceholders(strings.Join(v.Body, "\n")),
+		}
+		if v.Scope != "" || len(aliases) > 0 {
+			s.Attrs = make(map[string]string)
+		}
+		if v.Scope != "" {
+			s.Attrs["lang"] = v.Scope
+		}
+		if len(aliases) > 0 {
+			s.Attrs["aliases"] = strings.Join(aliases, ",")
+		}
+		result.add(s)
+	}
+	return result, nil
+}
+
+// vscodePlaceholders rewrites VS Code placeholders to the form used across
+// gsnip snippets: $1 becomes ${1} and the choice ${1|a,b|} becomes ${1:a}.
+// Placeholders with default text and variables are left as they are.
+func vscodePlaceholders(body string) string {
+	var b strings.Builder
+	rs := []rune(body)
+	for i := 0; i < len(rs); i++ {
+		switch {
+		case rs[i] == '\\' && i+1 < len(rs):
+			b.WriteRune(rs[i])
+			b.WriteRune(rs[i+1])
+			i++
+		case rs[i] == '$' && i+1 < len(rs) && unicode.IsDigit(rs[i+1]):
+			j := digits(rs, i+1)
+			fmt.Fprintf(&b, "${%s}", string(rs[i+1:j]))
+			i = j - 1
+		case rs[i] == '$' && i+2 < len(rs) && rs[i+1] == '{' && unicode.IsDigit(rs[i+2]):
+			j := digits(rs, i+2)
+			choice, end, ok := firstChoice(rs, j)
+			if !ok {
+				b.WriteRune(rs[i])
+				continue
+			}
+			fmt.Fprintf(&b, "${%s:%s}", string(rs[i+2:j]), choice)
+			i = end
+		default:
+			b.WriteRune(rs[i])
+		}
+	}
+	return b.String()
+}
+
+// digits returns the position right after the run of digits starting at i.
+func digits(rs []rune, i int) int {
+	for i < len(rs) && unicode.IsDigit(rs[i]) {
+		i++
+	}
+	return i
+}
+
+// firstChoice reads the choice list |a,b,c|} starting at position i. It
+// returns the first option and the position of the closing brace.
+func firstChoice(rs []rune, i int) (string, int, bool) {
+	if i >= len(rs) || rs[i] != '|' {
+		return "", 0, false
+	}
+	var b strings.Builder
+	first := true
+	for i++; i < len(rs); i++ {
+		switch {
+		case rs[i] == '\\' && i+1 < len(rs):
+			i++
+			if first {
+				b.WriteRune(rs[i])
+			}
+		case rs[i] == ',':
+			first = false
+		case rs[i] == '|' && i+1 < len(rs) && rs[i+1] == '}':
+			return b.String(), i + 1, true
+		default:
+			if first {
+				b.WriteRune(rs[i])
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// stripJSONC removes the comments and trailing commas that VS Code tolerates
+// in its JSON files.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == ']' || c == '}':
+			out = trimTrailingComma(out)
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// trimTrailingComma drops the comma preceding the closing bracket.
+func trimTrailingComma(out []byte) []byte {
+	i := len(out) - 1
+	for i >= 0 && unicode.IsSpace(rune(out[i])) {
+		i--
+	}
+	if i >= 0 && out[i] == ',' {
+		return append(out[:i], out[i+1:]...)
+	}
+	return out
+}
+
+// vscodeExporter writes snippets out as a VS Code .code-snippets file. The
+// aliases follow the name in the prefix list, and the lang attribute turns
+// into the scope of the snippet.
+type vscodeExporter struct{}
+
+func (vscodeExporter) Export(w io.Writer, snips []snippets.Snippet) error {
+	file := make(map[string]vscodeSnippet, len(snips))
+	for _, s := range snips {
+		file[s.Name] = vscodeSnippet{
+			Prefix:      append(stringList{s.Name}, s.Aliases()...),
+			Body:        strings.Split(s.Body, "\n"),
+			Description: stringList{s.Desc},
+			Scope:       s.Attrs["lang"],
+		}
+	}
+	return encodeJSON(w, file)
+}
+
+// MarshalJSON writes out a single string as a string rather than an array.
+func (l stringList) MarshalJSON() ([]byte, error) {
+	if len(l) == 1 {
+		return json.Marshal(l[0])
+	}
+	return json.Marshal([]string(l))
+}
@@ -0,0 +1,100 @@
+package convert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+const vscodeFile = `{
+	// Place your snippets here.
+	"For Loop": {
+		"prefix": ["for", "loop"],
+		"body": [
+			"for ${1:i} := 0; $1 < ${2|n,len(xs)|}; $1++ {",
+			"\t$0",
+			"}",
+		],
+		"description": "A for loop",
+		"scope": "go",
+	},
+	/* block
+	   comment */
+	"Print to console": {
+		"prefix": "log",
+		"body": "console.log('$1 costs \\$5');"
+	},
+	"Broken name": {
+		"prefix": "@LST",
+		"body": ""
+	},
+}`
+
+func TestVSCodeImport(t *testing.T) {
+	imp, err := NewImporter("vscode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err := imp.Import(strings.NewReader(vscodeFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []snippets.Snippet{
+		{
+			Name:  "for",
+			Desc:  "A for loop",
+			Body:  "for ${1:i} := 0; ${1} < ${2:n}; ${1}++ {\n\t${0}\n}",
+			Attrs: map[string]string{"lang": "go", "aliases": "loop"},
+		},
+		{
+			Name: "log",
+			Desc: "Print to console",
+			Body: "console.log('${1} costs \\$5');",
+		},
+	}
+	if !reflect.DeepEqual(has.Snippets, want) {
+		t.Errorf("want: %v; has: %v", want, has.Snippets)
+	}
+	if len(has.Warnings) != 1 {
+		t.Errorf("want a single warning; has: %v", has.Warnings)
+	}
+}
+
+func TestVSCodeImportFails(t *testing.T) {
+	inputs := []string{
+		`not json`,
+		`{"name": {"prefix": 1}}`,
+		`["array"]`,
+	}
+	imp, _ := NewImporter("vscode")
+	for _, i := range inputs {
+		if _, err := imp.Import(strings.NewReader(i)); err == nil {
+			t.Errorf("expected %s to fail", i)
+		}
+	}
+}
+
+func TestVSCodePlaceholders(t *testing.T) {
+	data := []struct {
+		body, want string
+	}{
+		{"$1 $22", "${1} ${22}"},
+		{"${1:default} ${TM_FILENAME} $TM_LINE", "${1:default} ${TM_FILENAME} $TM_LINE"},
+		{"${1|a\\,b,c|}", "${1:a,b}"},
+		{"${1:outer ${2|x,y|}}", "${1:outer ${2:x}}"},
+		{"\\$1 and ${1|unterminated", "\\$1 and ${1|unterminated"},
+	}
+	for _, d := range data {
+		if has := vscodePlaceholders(d.body); has != d.want {
+			t.Errorf("want: %s; has: %s", d.want, has)
+		}
+	}
+}
+
+func TestNewImporterFails(t *testing.T) {
+	if _, err := NewImporter("sublime"); err == nil {
+		t.Error("expected an error caused by unknown format")
+	}
+}
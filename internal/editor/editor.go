@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/mdm-code/gsnip/internal/fs"
 )
@@ -23,6 +24,15 @@ type Editor struct {
 // permanent file will be created. A nil pointer would mean that a temporary
 // file will be created.
 func NewEditor(fname *string) (*Editor, error) {
+	return NewProgramEditor(fname, os.Getenv("EDITOR"))
+}
+
+// NewProgramEditor creates a text editor like NewEditor, but opens the file
+// in prog rather than in $EDITOR.
+func NewProgramEditor(fname *string, prog string) (*Editor, error) {
+	if prog == "" {
+		return nil, fmt.Errorf("no editor: set $EDITOR or editor in the configuration")
+	}
 	var fh *fs.FileHandler
 	var err error
 	if fname == nil {
@@ -34,18 +44,20 @@ func NewEditor(fname *string) (*Editor, error) {
 		return nil, err
 	}
 
-	prog, ok := os.LookupEnv("EDITOR")
-	if !ok {
-		return nil, fmt.Errorf("$EDITOR is not set")
-	}
-
 	e := Editor{fh, prog}
 	return &e, nil
 }
 
+// Write puts the initial contents into the file before it is opened.
+func (e *Editor) Write(p []byte) (int, error) {
+	return e.handler.Write(p)
+}
+
 // Run opens the file in the text editor.
 func (e *Editor) Run() ([]byte, error) {
-	cmd := exec.Command(e.program, e.handler.Name())
+	// NOTE: the program may carry arguments, e.g. `code --wait`
+	args := append(strings.Fields(e.program), e.handler.Name())
+	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -0,0 +1,93 @@
+package fs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	// ErrLocked is raised when the lock on the file could not be acquired in
+	// time.
+	ErrLocked = errors.New("file is locked by another process")
+	// ErrModified is raised when the file was changed on disk by someone else.
+	ErrModified = errors.New("file was modified by another process")
+)
+
+// lockRetry is the interval between attempts to acquire the lock.
+const lockRetry = 50 * time.Millisecond
+
+// Stamp identifies the contents of a file at a point in time.
+type Stamp struct {
+	Size int64
+	Sum  []byte
+}
+
+// Lock acquires an exclusive advisory lock coordinating processes working on
+// the file, and returns the function releasing it. The lock is taken on the
+// <file>.lock file so that it is kept while the file itself is reopened or
+// replaced. Lock gives up with ErrLocked after the timeout.
+func (h *FileHandler) Lock(timeout time.Duration) (func() error, error) {
+	f, err := os.OpenFile(h.Name()+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w: %s", ErrLocked, h.Name())
+		}
+		time.Sleep(lockRetry)
+	}
+	return func() error {
+		// NOTE: Closing the file releases the lock
+		return f.Close()
+	}, nil
+}
+
+// Stamp returns the stamp of the file as it is on disk.
+func (h *FileHandler) Stamp() (Stamp, error) {
+	data, err := os.ReadFile(h.Name())
+	if err != nil {
+		return Stamp{}, err
+	}
+	sum := sha256.Sum256(data)
+	return Stamp{Size: int64(len(data)), Sum: sum[:]}, nil
+}
+
+// Modified reports whether the file on disk no longer matches the stamp. A
+// change of size gives the modification away right away; otherwise the
+// contents are compared, so touching the file does not count as a
+// modification while an edit within the same second and size still does.
+func (h *FileHandler) Modified(s Stamp) (bool, error) {
+	info, err := os.Stat(h.Name())
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != s.Size {
+		return true, nil
+	}
+	cur, err := h.Stamp()
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(cur.Sum, s.Sum), nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package fs
+
+import "os"
+
+// noFollow is not supported on platforms other than Unix.
+const noFollow = 0
+
+// tryLock always succeeds on platforms without flock, where only requests
+// within a single process are coordinated.
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
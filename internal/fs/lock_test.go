@@ -0,0 +1,67 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "snippets")
+	fh, err := NewFileHandler(fname, Perm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	unlock, err := fh.Lock(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NOTE: A separate open file description contends for the flock just
+	// like another process would
+	if _, err := fh.Lock(100 * time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Errorf("want: %v; has: %v", ErrLocked, err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err = fh.Lock(100 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to lock after release: %s", err)
+	}
+	unlock()
+}
+
+func TestModified(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "snippets")
+	os.WriteFile(fname, []byte("one"), 0644)
+	fh, err := NewFileHandler(fname, Perm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	s, err := fh.Stamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(want bool) {
+		t.Helper()
+		if has, err := fh.Modified(s); err != nil || has != want {
+			t.Errorf("want: %v; has: %v (%v)", want, has, err)
+		}
+	}
+
+	check(false)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(fname, later, later)
+	check(false)
+	os.WriteFile(fname, []byte("two"), 0644)
+	check(true)
+	os.WriteFile(fname, []byte("three"), 0644)
+	check(true)
+	os.Remove(fname)
+	check(true)
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// noFollow makes opening a file fail if its last path element is a symlink.
+const noFollow = syscall.O_NOFOLLOW
+
+// tryLock attempts to take an exclusive flock on the file without blocking.
+func tryLock(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,158 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PIDFile holds the process ID of a running server. The file stays locked for
+// as long as the server runs, so a PID file left behind by a crashed server is
+// told apart from one held by a live process.
+type PIDFile struct {
+	f *os.File
+}
+
+// CreatePID locks the PID file at path and writes the ID of the current
+// process to it. Its directory is created, accessible to the owner only, if
+// needed. CreatePID fails with ErrLocked if another process holds the file.
+func CreatePID(path string) (*PIDFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	for {
+		f, err := lockPID(path)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			return &PIDFile{f}, nil
+		}
+	}
+}
+
+// lockPID opens, locks and fills the PID file. It returns no file and no error
+// when the file was removed by the process releasing it in the meantime, and
+// the attempt has to be repeated.
+func lockPID(path string) (*os.File, error) {
+	// NOTE: The file is truncated only once the lock is taken so that the
+	// ID of a running process is never wiped out, and a symlink planted at
+	// the path is never followed
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|noFollow, 0600)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := tryLock(f)
+	if err == nil && !ok {
+		pid, _ := readPID(f)
+		err = fmt.Errorf("%w: %s is held by process %d", ErrLocked, path, pid)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fi, ferr := f.Stat()
+	pi, perr := os.Stat(path)
+	if ferr != nil || perr != nil || !os.SameFile(fi, pi) {
+		f.Close()
+		return nil, nil
+	}
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Cmdline is the command line a server was started with: its arguments and
+// the working directory relative paths among them are resolved against.
+type Cmdline struct {
+	Dir  string   `json:"dir"`
+	Args []string `json:"args"`
+}
+
+// Record stores the command line of the server after its process ID, so that
+// the server can be started again the same way.
+func (p *PIDFile) Record(c Cmdline) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if _, err := p.f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	_, err = p.f.Write(append(data, '\n'))
+	return err
+}
+
+// ReadCmdline returns the command line recorded in the PID file at path. A
+// missing file or a file with no command line yields an empty Cmdline.
+func ReadCmdline(path string) (Cmdline, error) {
+	var c Cmdline
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	_, line, _ := strings.Cut(string(data), "\n")
+	if line = strings.TrimSpace(line); line == "" {
+		return c, nil
+	}
+	if err := json.Unmarshal([]byte(line), &c); err != nil {
+		return c, fmt.Errorf("malformed PID file %s", path)
+	}
+	return c, nil
+}
+
+// Remove removes the PID file and releases its lock.
+func (p *PIDFile) Remove() error {
+	err := os.Remove(p.f.Name())
+	if cerr := p.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadPID returns the process ID stored in the PID file at path, and whether
+// the process holding it is still running. A missing file yields no process
+// and no error.
+func ReadPID(path string) (pid int, running bool, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	ok, err := tryLock(f)
+	if err != nil {
+		return 0, false, err
+	}
+	pid, err = readPID(f)
+	return pid, !ok, err
+}
+
+// readPID parses the process ID stored on the first line of the file.
+func readPID(f *os.File) (int, error) {
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return 0, err
+	}
+	first, _, _ := strings.Cut(string(data), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, fmt.Errorf("malformed PID file %s", f.Name())
+	}
+	return pid, nil
+}
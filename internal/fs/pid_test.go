@@ -0,0 +1,99 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "gsnip.pid")
+	if pid, running, err := ReadPID(path); pid != 0 || running || err != nil {
+		t.Errorf("missing file should report no process; has: %d %v %v", pid, running, err)
+	}
+	p, err := CreatePID(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(filepath.Dir(path)); fi.Mode().Perm() != 0700 {
+		t.Errorf("want: %v; has: %v", os.FileMode(0700), fi.Mode().Perm())
+	}
+	if pid, running, err := ReadPID(path); pid != os.Getpid() || !running || err != nil {
+		t.Errorf("want: %d running; has: %d %v %v", os.Getpid(), pid, running, err)
+	}
+	if _, err := CreatePID(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("want: %v; has: %v", ErrLocked, err)
+	}
+	if pid, _, _ := ReadPID(path); pid != os.Getpid() {
+		t.Errorf("failed attempt should keep the PID; has: %d", pid)
+	}
+	if err := p.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("PID file should be removed; has: %v", err)
+	}
+}
+
+func TestPIDFileSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks are followed on Windows")
+	}
+	dir := t.TempDir()
+	victim := filepath.Join(dir, "victim")
+	os.WriteFile(victim, []byte("data"), 0600)
+	path := filepath.Join(dir, "gsnip.pid")
+	if err := os.Symlink(victim, path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreatePID(path); err == nil {
+		t.Error("a symlink at the PID path should be refused")
+	}
+	if data, _ := os.ReadFile(victim); string(data) != "data" {
+		t.Errorf("the symlink target was written to: %q", data)
+	}
+}
+
+func TestPIDFileStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gsnip.pid")
+	os.WriteFile(path, []byte("999999\n"), 0600)
+	if pid, running, err := ReadPID(path); pid != 999999 || running || err != nil {
+		t.Errorf("unlocked file should report a stopped process; has: %d %v %v", pid, running, err)
+	}
+	p, err := CreatePID(path)
+	if err != nil {
+		t.Fatalf("stale PID file should be taken over: %s", err)
+	}
+	defer p.Remove()
+	if pid, _, _ := ReadPID(path); pid != os.Getpid() {
+		t.Errorf("want: %d; has: %d", os.Getpid(), pid)
+	}
+}
+
+func TestPIDFileCmdline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gsnip.pid")
+	if c, err := ReadCmdline(path); err != nil || c.Args != nil {
+		t.Errorf("missing file should yield no command line; has: %v %v", c, err)
+	}
+	p, err := CreatePID(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Remove()
+	if c, err := ReadCmdline(path); err != nil || c.Args != nil {
+		t.Errorf("want no command line; has: %v %v", c, err)
+	}
+	want := Cmdline{Dir: "/home/me", Args: []string{"-file", "my snippets", "-git"}}
+	if err := p.Record(want); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := ReadCmdline(path); err != nil || !reflect.DeepEqual(c, want) {
+		t.Errorf("want: %v; has: %v %v", want, c, err)
+	}
+	if pid, running, err := ReadPID(path); pid != os.Getpid() || !running || err != nil {
+		t.Errorf("want: %d running; has: %d %v %v", os.Getpid(), pid, running, err)
+	}
+}
@@ -0,0 +1,210 @@
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+var (
+	// ErrNothingToUndo is raised when there are no applied entries left.
+	ErrNothingToUndo = errors.New("nothing to undo")
+	// ErrNothingToRedo is raised when there are no undone entries left.
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrNoEntry is raised when the requested entry is not in the journal.
+	ErrNoEntry = errors.New("no such journal entry")
+)
+
+// Entry records a single mutation of the snippet collection. Before holds
+// the affected snippets as they were prior to the mutation, After holds them
+// as they were left by it.
+type Entry struct {
+	ID     int                `json:"id"`
+	Op     string             `json:"op"`
+	Time   time.Time          `json:"time"`
+	Before []snippets.Snippet `json:"before,omitempty"`
+	After  []snippets.Snippet `json:"after,omitempty"`
+}
+
+// Names lists the names of snippets affected by the mutation.
+func (e Entry) Names() []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, ss := range [][]snippets.Snippet{e.Before, e.After} {
+		for _, s := range ss {
+			if !seen[s.Name] {
+				seen[s.Name] = true
+				result = append(result, s.Name)
+			}
+		}
+	}
+	return result
+}
+
+// Journal keeps a bounded history of mutations. Entries past the cursor have
+// been undone and can be redone until a new mutation is recorded.
+type Journal struct {
+	path    string
+	size    int
+	entries []Entry
+	cursor  int
+	next    int
+	sync.Mutex
+}
+
+// state is the on-disk representation of the journal.
+type state struct {
+	Entries []Entry `json:"entries"`
+	Cursor  int     `json:"cursor"`
+	Next    int     `json:"next"`
+}
+
+// Open loads the journal stored at path keeping at most size entries. The
+// file is created on the first recorded mutation. An empty path keeps the
+// journal in memory only.
+func Open(path string, size int) (*Journal, error) {
+	j := &Journal{path: path, size: size, next: 1}
+	if path == "" {
+		return j, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("malformed journal %s: %w", path, err)
+	}
+	if st.Cursor < 0 || st.Cursor > len(st.Entries) {
+		st.Cursor = len(st.Entries)
+	}
+	j.entries, j.cursor, j.next = st.Entries, st.Cursor, st.Next
+	if j.next < 1 {
+		j.next = 1
+	}
+	j.trim()
+	return j, nil
+}
+
+// Record appends the mutation to the journal discarding undone entries.
+func (j *Journal) Record(op string, before, after []snippets.Snippet) error {
+	j.Lock()
+	defer j.Unlock()
+	e := Entry{
+		ID:     j.next,
+		Op:     op,
+		Time:   time.Now().UTC(),
+		Before: before,
+		After:  after,
+	}
+	j.next++
+	j.entries = append(j.entries[:j.cursor], e)
+	j.cursor = len(j.entries)
+	j.trim()
+	return j.save()
+}
+
+// PeekUndo returns the entry Undo would revert without moving the cursor.
+func (j *Journal) PeekUndo() (Entry, error) {
+	j.Lock()
+	defer j.Unlock()
+	if j.cursor == 0 {
+		return Entry{}, ErrNothingToUndo
+	}
+	return j.entries[j.cursor-1], nil
+}
+
+// PeekRedo returns the entry Redo would apply again without moving the
+// cursor.
+func (j *Journal) PeekRedo() (Entry, error) {
+	j.Lock()
+	defer j.Unlock()
+	if j.cursor == len(j.entries) {
+		return Entry{}, ErrNothingToRedo
+	}
+	return j.entries[j.cursor], nil
+}
+
+// Undo moves the cursor back and returns the entry to revert.
+func (j *Journal) Undo() (Entry, error) {
+	j.Lock()
+	defer j.Unlock()
+	if j.cursor == 0 {
+		return Entry{}, ErrNothingToUndo
+	}
+	j.cursor--
+	return j.entries[j.cursor], j.save()
+}
+
+// Redo moves the cursor forward and returns the entry to apply again.
+func (j *Journal) Redo() (Entry, error) {
+	j.Lock()
+	defer j.Unlock()
+	if j.cursor == len(j.entries) {
+		return Entry{}, ErrNothingToRedo
+	}
+	j.cursor++
+	return j.entries[j.cursor-1], j.save()
+}
+
+// Entries lists the journal entries starting from the most recent one along
+// with the number of entries that are currently applied.
+func (j *Journal) Entries() ([]Entry, int) {
+	j.Lock()
+	defer j.Unlock()
+	result := make([]Entry, len(j.entries))
+	for i, e := range j.entries {
+		result[len(j.entries)-1-i] = e
+	}
+	return result, j.cursor
+}
+
+// Entry returns the entry with the given id.
+func (j *Journal) Entry(id int) (Entry, error) {
+	j.Lock()
+	defer j.Unlock()
+	for _, e := range j.entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("%w: %d", ErrNoEntry, id)
+}
+
+// trim drops the oldest entries exceeding the size of the journal.
+func (j *Journal) trim() {
+	if j.size <= 0 || len(j.entries) <= j.size {
+		return
+	}
+	drop := len(j.entries) - j.size
+	j.entries = append([]Entry(nil), j.entries[drop:]...)
+	j.cursor -= drop
+	if j.cursor < 0 {
+		j.cursor = 0
+	}
+}
+
+// save writes the journal to a temporary file and moves it into place so that
+// a failed write never leaves a truncated journal behind.
+func (j *Journal) save() error {
+	if j.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(state{j.entries, j.cursor, j.next})
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
@@ -0,0 +1,135 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+var (
+	one = snippets.Snippet{Name: "one", Desc: "first", Body: "1"}
+	two = snippets.Snippet{Name: "two", Desc: "second", Body: "2"}
+)
+
+func TestUndoRedo(t *testing.T) {
+	j, _ := Open("", 10)
+	j.Record("insert", nil, []snippets.Snippet{one})
+	j.Record("delete", []snippets.Snippet{one}, nil)
+
+	e, err := j.Undo()
+	if err != nil || e.Op != "delete" {
+		t.Fatalf("want: delete; has: %v (%v)", e, err)
+	}
+	e, err = j.Undo()
+	if err != nil || e.Op != "insert" {
+		t.Fatalf("want: insert; has: %v (%v)", e, err)
+	}
+	if _, err := j.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("want: %v; has: %v", ErrNothingToUndo, err)
+	}
+	e, err = j.Redo()
+	if err != nil || e.Op != "insert" {
+		t.Fatalf("want: insert; has: %v (%v)", e, err)
+	}
+}
+
+func TestPeekKeepsCursor(t *testing.T) {
+	j, _ := Open("", 10)
+	if _, err := j.PeekUndo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("want: %v; has: %v", ErrNothingToUndo, err)
+	}
+	j.Record("insert", nil, []snippets.Snippet{one})
+	for i := 0; i < 2; i++ {
+		if e, err := j.PeekUndo(); err != nil || e.Op != "insert" {
+			t.Fatalf("want: insert; has: %v (%v)", e, err)
+		}
+	}
+	if _, err := j.PeekRedo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("want: %v; has: %v", ErrNothingToRedo, err)
+	}
+	j.Undo()
+	if e, err := j.PeekRedo(); err != nil || e.Op != "insert" {
+		t.Fatalf("want: insert; has: %v (%v)", e, err)
+	}
+	if _, applied := j.Entries(); applied != 0 {
+		t.Errorf("want: 0; has: %d", applied)
+	}
+}
+
+func TestRecordDiscardsUndone(t *testing.T) {
+	j, _ := Open("", 10)
+	j.Record("insert", nil, []snippets.Snippet{one})
+	j.Record("insert", nil, []snippets.Snippet{two})
+	j.Undo()
+	j.Record("update", []snippets.Snippet{one}, []snippets.Snippet{one})
+	if _, err := j.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("want: %v; has: %v", ErrNothingToRedo, err)
+	}
+	entries, applied := j.Entries()
+	if len(entries) != 2 || applied != 2 || entries[0].ID != 3 {
+		t.Errorf("unexpected entries: %v (applied %d)", entries, applied)
+	}
+}
+
+func TestBounded(t *testing.T) {
+	j, _ := Open("", 2)
+	for i := 0; i < 5; i++ {
+		j.Record("insert", nil, []snippets.Snippet{one})
+	}
+	entries, applied := j.Entries()
+	if len(entries) != 2 || applied != 2 {
+		t.Fatalf("want 2 entries; has: %d (applied %d)", len(entries), applied)
+	}
+	if entries[0].ID != 5 || entries[1].ID != 4 {
+		t.Errorf("oldest entries should be dropped: %v", entries)
+	}
+	if _, err := j.Entry(1); !errors.Is(err, ErrNoEntry) {
+		t.Errorf("want: %v; has: %v", ErrNoEntry, err)
+	}
+}
+
+func TestPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snippets.journal")
+	j, err := Open(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Record("insert", nil, []snippets.Snippet{one})
+	j.Record("update", []snippets.Snippet{one}, []snippets.Snippet{two})
+	j.Undo()
+
+	j, err = Open(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, applied := j.Entries()
+	if len(entries) != 2 || applied != 1 {
+		t.Fatalf("want 2 entries with 1 applied; has: %d, %d", len(entries), applied)
+	}
+	if !reflect.DeepEqual(entries[0].After, []snippets.Snippet{two}) {
+		t.Errorf("want: %v; has: %v", two, entries[0].After)
+	}
+	j.Record("delete", []snippets.Snippet{one}, nil)
+	if e, _ := j.Entry(3); e.Op != "delete" {
+		t.Errorf("IDs should continue after reopening: %v", e)
+	}
+}
+
+func TestOpenMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snippets.journal")
+	os.WriteFile(path, []byte("{"), 0644)
+	if _, err := Open(path, 10); err == nil {
+		t.Error("expected an error caused by malformed journal")
+	}
+}
+
+func TestEntryNames(t *testing.T) {
+	e := Entry{Before: []snippets.Snippet{one}, After: []snippets.Snippet{one, two}}
+	if has := e.Names(); !reflect.DeepEqual(has, []string{"one", "two"}) {
+		t.Errorf("has: %v", has)
+	}
+}
@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Options configure the logger.
+type Options struct {
+	// Level is the lowest severity logged: debug, info, warn or error.
+	Level string
+	// Format of the records: text or json.
+	Format string
+	// File the records are appended to. Empty writes them to stderr.
+	File string
+	// MaxSize is the size in bytes after which the file is rotated. Zero
+	// disables rotation.
+	MaxSize int64
+	// Keep is the number of rotated files kept as <file>.1, <file>.2 and so
+	// on, the most recent first.
+	Keep int
+}
+
+// New creates a logger writing records in the format and to the sink set in
+// the options. The returned closer closes the log file.
+//
+// Allowed formats (o.Format): text, json
+func New(o Options) (*slog.Logger, io.Closer, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(o.Level)); err != nil {
+		return nil, nil, fmt.Errorf("unknown log level: %s", o.Level)
+	}
+	var w io.WriteCloser = nopCloser{os.Stderr}
+	if o.File != "" {
+		r, err := newRotator(o.File, o.MaxSize, o.Keep)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = r
+	}
+	hopts := &slog.HandlerOptions{Level: level}
+	switch o.Format {
+	case "text", "":
+		return slog.New(slog.NewTextHandler(w, hopts)), w, nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, hopts)), w, nil
+	default:
+		w.Close()
+		return nil, nil, fmt.Errorf("log format (%s) is not implemented", o.Format)
+	}
+}
+
+// nopCloser keeps stderr open when the logger is closed.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// rotator appends to a file and rotates it once it grows past the maximum
+// size.
+type rotator struct {
+	path string
+	max  int64
+	keep int
+	f    *os.File
+	size int64
+	mu   sync.Mutex
+}
+
+// newRotator opens the file for appending.
+func newRotator(path string, max int64, keep int) (*rotator, error) {
+	r := &rotator{path: path, max: max, keep: keep}
+	return r, r.open()
+}
+
+func (r *rotator) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+// Write appends the record, rotating the file first if the record would
+// make it grow past the maximum size. A failed rotation is reported on
+// stderr, and the record is appended to the file kept open instead.
+func (r *rotator) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.max > 0 && r.size > 0 && r.size+int64(len(p)) > r.max {
+		if err := r.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "could not rotate %s: %s\n", r.path, err)
+			// NOTE: Rotation is attempted again once the file grows by the
+			// maximum size, so rotated files are not shifted on every write
+			r.size = 0
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files by one, dropping the oldest one, and starts
+// a new file. The current file is closed only once the new one is open.
+func (r *rotator) rotate() error {
+	name := func(i int) string { return r.path + "." + strconv.Itoa(i) }
+	os.Remove(name(r.keep))
+	for i := r.keep - 1; i >= 1; i-- {
+		os.Rename(name(i), name(i+1))
+	}
+	var err error
+	if r.keep > 0 {
+		err = os.Rename(r.path, name(1))
+	} else {
+		err = os.Remove(r.path)
+	}
+	if err != nil {
+		return err
+	}
+	old := r.f
+	if err := r.open(); err != nil {
+		return err
+	}
+	return old.Close()
+}
+
+// Close closes the file.
+func (r *rotator) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
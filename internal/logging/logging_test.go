@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	data := []struct {
+		name string
+		o    Options
+		ok   bool
+	}{
+		{"text", Options{Level: "info", Format: "text"}, true},
+		{"json", Options{Level: "DEBUG", Format: "json"}, true},
+		{"default format", Options{Level: "warn"}, true},
+		{"unknown level", Options{Level: "loud"}, false},
+		{"unknown format", Options{Level: "info", Format: "xml"}, false},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			l, c, err := New(d.o)
+			if (err == nil) != d.ok {
+				t.Fatalf("want ok: %v; has: %v", d.ok, err)
+			}
+			if err == nil && (l == nil || c.Close() != nil) {
+				t.Error("want a logger and a closer")
+			}
+		})
+	}
+}
+
+func TestFileLevelAndFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gsnipd.log")
+	l, c, err := New(Options{Level: "info", Format: "json", File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("dropped")
+	l.Info("request", "op", "find", "snippet", "iferr")
+	c.Close()
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("want one record; has: %q", data)
+	}
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["level"] != "INFO" || rec["op"] != "find" || rec["snippet"] != "iferr" {
+		t.Errorf("has: %v", rec)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gsnipd.log")
+	r, err := newRotator(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		if _, err := r.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Close()
+	want := map[string]string{
+		path:        "dddddd\n",
+		path + ".1": "cccccc\n",
+		path + ".2": "bbbbbb\n",
+	}
+	for p, w := range want {
+		if has, _ := os.ReadFile(p); string(has) != w {
+			t.Errorf("%s: want: %q; has: %q", p, w, has)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("only two rotated files should be kept; has: %v", err)
+	}
+}
+
+func TestRotateFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gsnipd.log")
+	r, err := newRotator(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// NOTE: The file cannot be renamed over a directory that is not empty
+	os.MkdirAll(filepath.Join(path+".1", "x"), 0700)
+	for _, s := range []string{"aaaaaa\n", "bbbbbb\n"} {
+		if _, err := r.Write([]byte(s)); err != nil {
+			t.Fatalf("a failed rotation should not fail writes: %v", err)
+		}
+	}
+	if has, _ := os.ReadFile(path); string(has) != "aaaaaa\nbbbbbb\n" {
+		t.Errorf("records should be kept in the current file; has: %q", has)
+	}
+	os.RemoveAll(path + ".1")
+	for _, s := range []string{"cccccc\n", "dddddd\n"} {
+		if _, err := r.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if has, _ := os.ReadFile(path); string(has) != "dddddd\n" {
+		t.Errorf("the file should be rotated again; has: %q", has)
+	}
+}
@@ -1,64 +1,213 @@
 package manager
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/mdm-code/gsnip/internal/backup"
 	"github.com/mdm-code/gsnip/internal/fs"
+	"github.com/mdm-code/gsnip/internal/journal"
+	"github.com/mdm-code/gsnip/internal/metrics"
 	"github.com/mdm-code/gsnip/internal/parsing"
 	"github.com/mdm-code/gsnip/internal/snippets"
+	"github.com/mdm-code/gsnip/internal/stats"
 	"github.com/mdm-code/gsnip/internal/stream"
+	"github.com/mdm-code/gsnip/internal/vcs"
 )
 
+var (
+	// ErrUnsupported is raised when the requested operation is not supported.
+	ErrUnsupported = errors.New("request not supported")
+	// ErrStale is raised when the snippet no longer has the revision the
+	// client expects.
+	ErrStale = errors.New("stale snippet revision")
+	// ErrBadRequest is raised when the request body is malformed.
+	ErrBadRequest = errors.New("malformed request")
+)
+
+// lockTimeout is how long a request waits for another process to release the
+// source file.
+var lockTimeout = 5 * time.Second
+
+// writes lists operations that rewrite the source file.
+var writes = map[stream.Opcode]bool{
+	stream.Insert:  true,
+	stream.Update:  true,
+	stream.Delete:  true,
+	stream.Undo:    true,
+	stream.Redo:    true,
+	stream.Restore: true,
+	stream.Rename:  true,
+}
+
 // Manager integrates operations on snippets stored in a file.
 type Manager struct {
 	fh      *fs.FileHandler
 	c       snippets.Container
 	p       *parsing.Parser
+	f       parsing.Format
+	j       *journal.Journal
+	b       *backup.Store
+	g       *vcs.Repo
+	u       *stats.Stats
+	metrics *metrics.Metrics
+	user    string
+	expect  string
+	rev     string
+	stamp   *fs.Stamp
+	loaded  time.Time
+	loadErr error
+	mu      sync.Mutex
 	actions map[stream.Opcode]interface{}
 }
 
+// Options configures the optional features of the Manager.
+type Options struct {
+	// Format of the source file. It is picked by the file extension when nil.
+	Format parsing.Format
+	// History is the number of mutations kept in the journal stored next to
+	// the source file. Zero disables the journal.
+	History int
+	// Backups keeps copies of the source file taken before each rewrite.
+	// Nil disables backups.
+	Backups *backup.Store
+	// Git commits the source file after each mutation to the repository it
+	// is kept in. Nil disables commits.
+	Git *vcs.Repo
+	// Stats records the use of snippets found and ranks listed snippets by
+	// frecency. Nil disables usage statistics.
+	Stats *stats.Stats
+	// Metrics records how long rewrites of the source file take. Nil
+	// disables the measurements.
+	Metrics *metrics.Metrics
+}
+
 // NewManager creates a pointer to a Manager instance for a given file handle.
-func NewManager(fh *fs.FileHandler) (*Manager, error) {
+// Snippets are read from and written back to the file in the format set in
+// the options.
+func NewManager(fh *fs.FileHandler, o Options) (*Manager, error) {
 	parser := parsing.NewParser()
-	snpts, err := parser.Parse(fh)
+	f := o.Format
+	if f == nil {
+		f = parsing.FormatFor(fh.Name())
+	}
+	snpts, err := f.Load(fh)
 	actions := map[stream.Opcode]interface{}{
-		stream.Find:   (*Manager).find,
-		stream.Insert: (*Manager).insert,
-		stream.Delete: (*Manager).delete,
-		stream.Reload: (*Manager).reload,
-		stream.List:   (*Manager).list,
+		stream.Find:    (*Manager).find,
+		stream.Insert:  (*Manager).insert,
+		stream.Delete:  (*Manager).delete,
+		stream.Reload:  (*Manager).reload,
+		stream.List:    (*Manager).list,
+		stream.Update:  (*Manager).update,
+		stream.Dump:    (*Manager).dump,
+		stream.Undo:    (*Manager).undo,
+		stream.Redo:    (*Manager).redo,
+		stream.History: (*Manager).history,
+		stream.Backups: (*Manager).backups,
+		stream.Restore: (*Manager).restore,
+		stream.Log:     (*Manager).log,
+		stream.Show:    (*Manager).show,
+		stream.Usage:   (*Manager).usage,
+		stream.Unused:  (*Manager).unused,
+		stream.Rename:  (*Manager).rename,
 	}
 	if err != nil && !errors.Is(err, parsing.ErrEmptyFile) {
 		return newManager(nil, nil, nil, actions), err
 	}
-	return newManager(fh, snpts, &parser, actions), nil
+	m := newManager(fh, snpts, &parser, actions)
+	m.f = f
+	m.loaded = time.Now()
+	st, err := fh.Stamp()
+	if err != nil {
+		return m, err
+	}
+	m.stamp = &st
+	m.b = o.Backups
+	m.g = o.Git
+	m.u = o.Stats
+	m.metrics = o.Metrics
+	if m.g != nil {
+		// NOTE: Commit changes made outside the server first so that they are
+		// not attributed to the next mutation
+		err = m.g.Commit("gsnip: snapshot " + filepath.Base(fh.Name()))
+		if err != nil {
+			return m, err
+		}
+	}
+	if o.History > 0 {
+		m.j, err = journal.Open(JournalPath(fh.Name()), o.History)
+		if err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// JournalPath returns the path of the journal kept for the source file.
+func JournalPath(fname string) string {
+	return fname + ".journal"
 }
 
 func newManager(fh *fs.FileHandler, snpts snippets.Container, p *parsing.Parser, actns map[stream.Opcode]interface{}) *Manager {
-	return &Manager{fh, snpts, p, actns}
+	f, _ := parsing.NewFormat("gsnip")
+	return &Manager{fh: fh, c: snpts, p: p, f: f, actions: actns}
 }
 
 // Execute runs a server command against the snippet container.
 //
 // Allowed commands:
-// 	* List out all stored snippets
-// 	* Find a single snippet
-// 	* Insert a snippet to the container
-// 	* Delete a snippet from the container
-//  * Reload the snippet container
+//   - List out all stored snippets
+//   - Find a single snippet
+//   - Insert a snippet to the container
+//   - Delete snippets from the container in one batch
+//   - Reload the snippet container
+//   - Update snippets by replacing the ones stored under the same names
+//   - Dump all snippets in the source file syntax
+//   - Undo or redo the most recent mutation
+//   - History of mutations or a single journal entry
+//   - Backups of the source file listed out
+//   - Restore the source file from a backup
+//   - Log the commits changing a snippet
+//   - Show a snippet at a given commit
+//   - Usage statistics of snippets
+//   - Unused snippets within a time window
+//   - Rename a snippet keeping its aliases
+//
+// Requests are executed one at a time. Requests rewriting or reloading the
+// source file also hold the advisory lock on it, and rewrites fail with a
+// conflict if the file was modified by another process since it was loaded.
+// On failure, the reply carries the error message along with its code.
 func (m *Manager) Execute(request stream.Request, reply *stream.Reply) error {
 	var body string
 	var err error
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.user, m.expect, m.rev = request.User, request.Rev, ""
+
 	op, ok := m.actions[request.Operation]
 
 	if !ok {
-		err = fmt.Errorf("request %v not supported", request.Operation)
+		err = fmt.Errorf("%w: %v", ErrUnsupported, request.Operation)
+	}
+
+	if ok && (writes[request.Operation] || request.Operation == stream.Reload) {
+		var unlock func() error
+		unlock, err = m.guard(writes[request.Operation])
+		if err == nil {
+			defer unlock()
+		}
 	}
 
-	if ok {
+	if ok && err == nil {
 		switch f := op.(type) {
 		case func(*Manager) (string, error):
 			body, err = f(m)
@@ -71,35 +220,186 @@ func (m *Manager) Execute(request stream.Request, reply *stream.Reply) error {
 
 	if err != nil {
 		reply.Result = stream.Failure
+		reply.Body = []byte(err.Error())
+		reply.Code = code(err)
 	} else {
 		reply.Result = stream.Success
 		reply.Body = []byte(body)
+		reply.Code = stream.OK
+		reply.Rev = m.rev
 	}
 	return err
 }
 
+// code classifies the error for the client.
+func code(err error) stream.Code {
+	switch {
+	case errors.Is(err, ErrUnsupported):
+		return stream.Unsupported
+	case errors.Is(err, snippets.ErrNotFound),
+		errors.Is(err, journal.ErrNoEntry),
+		errors.Is(err, backup.ErrNoBackup),
+		errors.Is(err, vcs.ErrBadRev):
+		return stream.NotFound
+	case errors.Is(err, snippets.ErrExists),
+		errors.Is(err, snippets.ErrAliasTaken):
+		return stream.Exists
+	case errors.Is(err, fs.ErrLocked),
+		errors.Is(err, fs.ErrModified),
+		errors.Is(err, ErrStale):
+		return stream.Conflict
+	case errors.Is(err, snippets.ErrInvalid),
+		errors.Is(err, ErrBadRequest),
+		errors.Is(err, parsing.ErrLine),
+		errors.Is(err, parsing.ErrEmptyFile):
+		return stream.Invalid
+	default:
+		return stream.Unknown
+	}
+}
+
+// Count returns the number of snippets stored.
+func (m *Manager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.c == nil {
+		return 0
+	}
+	snips, _ := m.c.ListObj()
+	return len(snips)
+}
+
+// Capabilities lists the names of the operations the manager supports with
+// the options it was created with, followed by the features it offers.
+func (m *Manager) Capabilities() []string {
+	disabled := map[stream.Opcode]bool{
+		stream.Undo:    m.j == nil,
+		stream.Redo:    m.j == nil,
+		stream.History: m.j == nil,
+		stream.Backups: m.b == nil,
+		stream.Restore: m.b == nil,
+		stream.Log:     m.g == nil,
+		stream.Show:    m.g == nil,
+		stream.Usage:   m.u == nil,
+		stream.Unused:  m.u == nil,
+	}
+	var caps []string
+	for _, op := range stream.Operations() {
+		if _, ok := m.actions[op]; ok && !disabled[op] {
+			caps = append(caps, op.String())
+		}
+	}
+	return append(caps, stream.FeatureBatch, stream.FeatureAliases, stream.FeatureRevisions)
+}
+
+// Reloaded returns the time the source file was last loaded and the error
+// the load failed with, if any.
+func (m *Manager) Reloaded() (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.loaded, m.loadErr
+}
+
+// list lists out names, descriptions and revisions of all snippets followed by
+// their aliases, if any. With usage statistics enabled, the most frecently
+// used snippets come first.
 func (m *Manager) list() (string, error) {
-	result := ""
-	listing, err := m.c.List()
+	snips, err := m.ranked()
+	if err != nil {
+		return "", fmt.Errorf("failed to list snippets")
+	}
+	var b strings.Builder
+	for _, s := range snips {
+		fmt.Fprintf(&b, "%s\t%s\t%s", s.Name, s.Desc, s.Rev())
+		if aliases := s.Aliases(); len(aliases) > 0 {
+			fmt.Fprintf(&b, "\t%s", strings.Join(aliases, ","))
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// usage lists out use counts, last use times and frecency scores of all
+// snippets starting from the highest score.
+func (m *Manager) usage() (string, error) {
+	if m.u == nil {
+		return "", fmt.Errorf("%w: usage statistics are disabled", ErrUnsupported)
+	}
+	snips, err := m.ranked()
+	if err != nil {
+		return "", fmt.Errorf("failed to list snippets")
+	}
+	now := m.u.Now()
+	var b strings.Builder
+	for _, s := range snips {
+		u := m.u.Get(s.Name)
+		last := "never"
+		if u.Hits > 0 {
+			last = u.Last.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(&b, "%s\t%d\t%s\t%d\n", s.Name, u.Hits, last, u.Score(now))
+	}
+	return b.String(), nil
+}
+
+// ranked lists snippets ordered by frecency when usage statistics are
+// enabled and by name otherwise.
+func (m *Manager) ranked() ([]snippets.Snippet, error) {
+	snips, err := m.c.ListObj()
+	if err != nil || m.u == nil {
+		return snips, err
+	}
+	names := make([]string, len(snips))
+	byName := make(map[string]snippets.Snippet, len(snips))
+	for i, s := range snips {
+		names[i] = s.Name
+		byName[s.Name] = s
+	}
+	m.u.Rank(names)
+	for i, n := range names {
+		snips[i] = byName[n]
+	}
+	return snips, nil
+}
+
+func (m *Manager) dump() (string, error) {
+	snips, err := m.c.ListObj()
 	if err != nil {
 		return "", fmt.Errorf("failed to list snippets")
 	}
-	for _, s := range listing {
-		result = result + s + "\n"
+	var b strings.Builder
+	for _, s := range snips {
+		b.WriteString(s.Repr())
 	}
-	return result, nil
+	return b.String(), nil
 }
 
 func (m *Manager) find(s string) (string, error) {
 	var searched snippets.Snippet
 	var err error
 	if searched, err = m.c.Find(s); err != nil {
-		return "", fmt.Errorf("%s was not found", s)
+		return "", err
+	}
+	m.rev = searched.Rev()
+	if m.u != nil {
+		// NOTE: Usage statistics are best-effort, so failing to save them
+		// does not fail the lookup
+		m.u.Hit(searched.Name)
 	}
 	return searched.Body, nil
 }
 
 func (m *Manager) insert(contents string) (string, error) {
+	return m.store(contents, "insert", m.c.Insert)
+}
+
+func (m *Manager) update(contents string) (string, error) {
+	return m.store(contents, "update", m.c.Update)
+}
+
+// store parses snippets in contents and puts them into the container with the
+// provided function. The mutation is recorded in the journal as op.
+func (m *Manager) store(contents, op string, put func(snippets.Snippet) error) (string, error) {
 	reader := strings.NewReader(contents)
 	container, err := m.p.Parse(reader)
 	if err != nil {
@@ -110,58 +410,599 @@ func (m *Manager) insert(contents string) (string, error) {
 	if err != nil {
 		return "ERROR", err
 	}
+	if m.expect != "" {
+		if len(snips) != 1 {
+			return "ERROR", fmt.Errorf(
+				"%w: a revision applies to a single snippet",
+				ErrBadRequest,
+			)
+		}
+		if err := m.check(snips[0].Name, m.expect); err != nil {
+			return "ERROR", err
+		}
+	}
 
-	for _, p := range snips {
-		err = m.c.Insert(p)
+	var before []snippets.Snippet
+	var added []string
+	for i, p := range snips {
+		old, ferr := m.c.Find(p.Name)
+		err = put(p)
 		if err != nil {
+			// NOTE: The batch is stored as a whole or not at all
+			m.revert(snips[:i], before)
 			return "ERROR", err
 		}
+		if ferr == nil {
+			before = append(before, old)
+		} else {
+			added = append(added, p.Name)
+		}
 	}
 
-	// NOTE: Rewrite file contents
-	snips, err = m.c.ListObj()
+	err = m.write()
 	if err != nil {
+		m.revert(snips, before)
 		return "ERROR", err
 	}
-	err = m.fh.Truncate(0)
-	for _, s := range snips {
-		m.fh.Write([]byte(s.Repr()))
+	if m.u != nil {
+		// NOTE: New snippets are not pruned before they had the time to be
+		// used; statistics are best-effort as they are for finds
+		for _, n := range added {
+			m.u.Add(n)
+		}
 	}
-	err = m.reload()
+	err = m.record(op, before, snips)
 	if err != nil {
 		return "ERROR", err
 	}
 	return "", nil
 }
 
+// revert takes the snippets put into the container out of it and puts back
+// the ones they replaced.
+func (m *Manager) revert(put, replaced []snippets.Snippet) {
+	for i := len(put) - 1; i >= 0; i-- {
+		m.c.Delete(put[i].Name)
+	}
+	for _, s := range replaced {
+		m.c.Update(s)
+	}
+}
+
+// delete deletes snippets whose names are separated with white space in one
+// batch recorded as a single mutation. The expected revisions, if any, are
+// separated with white space in the same order, and nothing is deleted
+// unless all of them match.
 func (m *Manager) delete(s string) (string, error) {
-	m.c.Delete(s)
-	snips, err := m.c.ListObj()
+	names := strings.Fields(s)
+	if m.expect != "" {
+		revs := strings.Fields(m.expect)
+		if len(revs) != len(names) {
+			return "ERROR", fmt.Errorf(
+				"%w: expected a revision for each snippet",
+				ErrBadRequest,
+			)
+		}
+		for i, n := range names {
+			if err := m.check(n, revs[i]); err != nil {
+				return "ERROR", err
+			}
+		}
+	}
+	var before []snippets.Snippet
+	for _, n := range names {
+		// NOTE: Aliases resolve to the snippets declaring them
+		if old, err := m.c.Find(n); err == nil {
+			before = append(before, old)
+			m.c.Delete(old.Name)
+		}
+	}
+	err := m.write()
 	if err != nil {
+		m.revert(nil, before)
 		return "ERROR", err
 	}
-	err = m.fh.Truncate(0)
-	for _, s := range snips {
-		m.fh.Write([]byte(s.Repr()))
+	if len(before) > 0 {
+		err = m.record("delete", before, nil)
+		if err != nil {
+			return "ERROR", err
+		}
 	}
+	return "", nil
+}
 
-	err = m.reload()
+// rename gives the snippet a new name keeping its description, attributes
+// and aliases. The body holds the old and the new name.
+func (m *Manager) rename(s string) (string, error) {
+	names := strings.Fields(s)
+	if len(names) != 2 {
+		return "ERROR", fmt.Errorf("%w: expected OLD NEW names", ErrBadRequest)
+	}
+	if err := m.check(names[0], m.expect); err != nil {
+		return "ERROR", err
+	}
+	old, err := m.c.Find(names[0])
+	if err != nil {
+		return "ERROR", err
+	}
+	renamed := old
+	renamed.Name = names[1]
+	if renamed.Name == old.Name {
+		return "", nil
+	}
+	m.c.Delete(old.Name)
+	if err := m.c.Insert(renamed); err != nil {
+		m.c.Update(old)
+		return "ERROR", err
+	}
+	err = m.write()
+	if err != nil {
+		m.revert([]snippets.Snippet{renamed}, []snippets.Snippet{old})
+		return "ERROR", err
+	}
+	m.follow(old, renamed)
+	err = m.record("rename", []snippets.Snippet{old}, []snippets.Snippet{renamed})
 	if err != nil {
 		return "ERROR", err
 	}
 	return "", nil
 }
 
-func (m *Manager) reload() error {
-	err := m.fh.Reload()
+// follow moves the usage statistics of the snippet renamed from old to new.
+// Statistics are best-effort, as they are for finds.
+func (m *Manager) follow(old, new snippets.Snippet) {
+	if m.u != nil && old.Name != new.Name {
+		m.u.Rename(old.Name, new.Name)
+	}
+}
+
+// unused lists snippets not found within the window given as a duration,
+// along with their last use, use count and revision. The duration may be
+// followed by a number of hits: snippets found no more than that many times
+// are listed as well. Snippets count only if they existed for the whole
+// window, and snippets never found are listed with the zero default.
+func (m *Manager) unused(s string) (string, error) {
+	if m.u == nil {
+		return "", fmt.Errorf("%w: usage statistics are disabled", ErrUnsupported)
+	}
+	args := strings.Fields(s)
+	if len(args) == 0 || len(args) > 2 {
+		return "", fmt.Errorf("%w: expected a time window and hits: %q", ErrBadRequest, s)
+	}
+	window, err := time.ParseDuration(args[0])
+	if err != nil || window <= 0 {
+		return "", fmt.Errorf("%w: bad time window: %q", ErrBadRequest, args[0])
+	}
+	hits := 0
+	if len(args) == 2 {
+		hits, err = strconv.Atoi(args[1])
+		if err != nil || hits < 0 {
+			return "", fmt.Errorf("%w: bad number of hits: %q", ErrBadRequest, args[1])
+		}
+	}
+	cutoff := m.u.Now().Add(-window)
+	snips, err := m.c.ListObj()
+	if err != nil {
+		return "", fmt.Errorf("failed to list snippets")
+	}
+	var b strings.Builder
+	for _, s := range snips {
+		if m.u.Seen(s.Name).After(cutoff) {
+			continue
+		}
+		u := m.u.Get(s.Name)
+		if u.Hits > hits && !u.Last.Before(cutoff) {
+			continue
+		}
+		last := "never"
+		if u.Hits > 0 {
+			last = u.Last.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%d\t%s\n", s.Name, last, u.Hits, s.Rev())
+	}
+	return b.String(), nil
+}
+
+// check verifies that the snippet has the revision rev expected by the
+// client. An empty rev skips the check.
+func (m *Manager) check(name, rev string) error {
+	if rev == "" {
+		return nil
+	}
+	s, err := m.c.Find(name)
+	if err != nil {
+		return fmt.Errorf("%w: %s was deleted", ErrStale, name)
+	}
+	if has := s.Rev(); has != rev {
+		return fmt.Errorf(
+			"%w: %s is at %s, not %s",
+			ErrStale,
+			name,
+			has,
+			rev,
+		)
+	}
+	return nil
+}
+
+// record adds the mutation to the journal and commits it to the repository
+// when they are enabled.
+func (m *Manager) record(op string, before, after []snippets.Snippet) error {
+	if m.j != nil {
+		if err := m.j.Record(op, before, after); err != nil {
+			return fmt.Errorf("failed to record %s in history: %w", op, err)
+		}
+	}
+	return m.commit(journal.Entry{Op: op, Before: before, After: after})
+}
+
+// commit commits the source file with a message describing the mutation.
+func (m *Manager) commit(e journal.Entry) error {
+	if m.g == nil {
+		return nil
+	}
+	msg := fmt.Sprintf("gsnip: %s %s", e.Op, strings.Join(e.Names(), ", "))
+	if m.user != "" {
+		msg += "\n\nUser: " + m.user
+	}
+	if err := m.g.Commit(msg); err != nil {
+		return fmt.Errorf("failed to commit %s: %w", e.Op, err)
+	}
+	return nil
+}
+
+func (m *Manager) undo() (string, error) {
+	if m.j == nil {
+		return "", fmt.Errorf("%w: history is disabled", ErrUnsupported)
+	}
+	// NOTE: The cursor moves only once the change reached the file
+	e, err := m.j.PeekUndo()
 	if err != nil {
+		return "", err
+	}
+	if err := m.apply(e.After, e.Before); err != nil {
+		return "", err
+	}
+	if _, err := m.j.Undo(); err != nil {
+		return "", err
+	}
+	if e.Op == "rename" {
+		m.follow(e.After[0], e.Before[0])
+	}
+	return "undone: " + describe(e), m.commit(journal.Entry{
+		Op:     "undo " + e.Op,
+		Before: e.Before,
+		After:  e.After,
+	})
+}
+
+func (m *Manager) redo() (string, error) {
+	if m.j == nil {
+		return "", fmt.Errorf("%w: history is disabled", ErrUnsupported)
+	}
+	// NOTE: The cursor moves only once the change reached the file
+	e, err := m.j.PeekRedo()
+	if err != nil {
+		return "", err
+	}
+	if err := m.apply(e.Before, e.After); err != nil {
+		return "", err
+	}
+	if _, err := m.j.Redo(); err != nil {
+		return "", err
+	}
+	if e.Op == "rename" {
+		m.follow(e.Before[0], e.After[0])
+	}
+	return "redone: " + describe(e), m.commit(journal.Entry{
+		Op:     "redo " + e.Op,
+		Before: e.Before,
+		After:  e.After,
+	})
+}
+
+// apply replaces the snippets in drop with the ones in put and writes the
+// source file. The container is left as it was if that fails.
+func (m *Manager) apply(drop, put []snippets.Snippet) error {
+	for _, s := range drop {
+		m.c.Delete(s.Name)
+	}
+	for i, s := range put {
+		if err := m.c.Update(s); err != nil {
+			m.revert(put[:i], drop)
+			return err
+		}
+	}
+	if err := m.write(); err != nil {
+		m.revert(put, drop)
 		return err
 	}
-	parser := parsing.NewParser()
-	snpts, err := parser.Parse(m.fh)
+	return nil
+}
+
+// history lists journal entries from the most recent one. With an entry ID
+// in the body, it shows the snippets before and after that mutation.
+func (m *Manager) history(s string) (string, error) {
+	if m.j == nil {
+		return "", fmt.Errorf("%w: history is disabled", ErrUnsupported)
+	}
+	s = strings.TrimSpace(s)
+	if s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", journal.ErrNoEntry, s)
+		}
+		e, err := m.j.Entry(id)
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s\n--- before\n", describe(e))
+		for _, s := range e.Before {
+			b.WriteString(s.Repr())
+		}
+		b.WriteString("+++ after\n")
+		for _, s := range e.After {
+			b.WriteString(s.Repr())
+		}
+		return b.String(), nil
+	}
+	entries, applied := m.j.Entries()
+	var b strings.Builder
+	for i, e := range entries {
+		fmt.Fprintf(&b, "%s", describe(e))
+		if len(entries)-1-i >= applied {
+			b.WriteString("\t(undone)")
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// describe summarizes the journal entry in a single line.
+func describe(e journal.Entry) string {
+	return fmt.Sprintf(
+		"%d\t%s\t%s\t%s",
+		e.ID,
+		e.Time.Local().Format("2006-01-02 15:04:05"),
+		e.Op,
+		strings.Join(e.Names(), ","),
+	)
+}
+
+// backups lists the copies of the source file from the most recent one.
+func (m *Manager) backups() (string, error) {
+	if m.b == nil {
+		return "", fmt.Errorf("%w: backups are disabled", ErrUnsupported)
+	}
+	backups, err := m.b.List()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, bk := range backups {
+		fmt.Fprintf(
+			&b,
+			"%s\t%s\t%d\n",
+			bk.ID,
+			bk.Time.Local().Format("2006-01-02 15:04:05"),
+			bk.Size,
+		)
+	}
+	return b.String(), nil
+}
+
+// restore replaces the snippets with the ones stored in the backup. The
+// current source file is backed up first, and the restore is recorded in
+// the journal so that it can be undone.
+func (m *Manager) restore(id string) (string, error) {
+	if m.b == nil {
+		return "", fmt.Errorf("%w: backups are disabled", ErrUnsupported)
+	}
+	id = strings.TrimSpace(id)
+	data, err := m.b.Load(id)
+	if err != nil {
+		return "", err
+	}
+	restored, err := m.f.Load(bytes.NewReader(data))
+	if err != nil && !errors.Is(err, parsing.ErrEmptyFile) {
+		return "", fmt.Errorf("backup %s: %w", id, err)
+	}
+	current := m.c
+	before, err := current.ListObj()
+	if err != nil {
+		return "", err
+	}
+	after, err := restored.ListObj()
+	if err != nil {
+		return "", err
+	}
+	m.c = restored
+	if err := m.write(); err != nil {
+		m.c = current
+		return "", err
+	}
+	if err := m.record("restore", before, after); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("restored %d snippets from %s", len(after), id), nil
+}
+
+// log lists the commits that changed the snippet starting from the most
+// recent one.
+func (m *Manager) log(name string) (string, error) {
+	if m.g == nil {
+		return "", fmt.Errorf("%w: git history is disabled", ErrUnsupported)
+	}
+	name = strings.TrimSpace(name)
+	commits, err := m.g.Log()
+	if err != nil {
+		return "", err
+	}
+	var changed []vcs.Commit
+	var prev *snippets.Snippet
+	for i := len(commits) - 1; i >= 0; i-- {
+		s, err := m.at(name, commits[i].Rev)
+		if errors.Is(err, snippets.ErrNotFound) {
+			s, err = nil, nil
+		}
+		if err != nil {
+			// NOTE: Revisions that fail to load do not tell anything about
+			// the snippet
+			continue
+		}
+		if !reflect.DeepEqual(s, prev) {
+			changed = append(changed, commits[i])
+		}
+		prev = s
+	}
+	if len(changed) == 0 {
+		return "", fmt.Errorf("%w: %s", snippets.ErrNotFound, name)
+	}
+	var b strings.Builder
+	for i := len(changed) - 1; i >= 0; i-- {
+		c := changed[i]
+		fmt.Fprintf(
+			&b,
+			"%s\t%s\t%s\t%s\n",
+			c.Rev,
+			c.Time.Local().Format("2006-01-02 15:04:05"),
+			c.Author,
+			c.Subject,
+		)
+	}
+	return b.String(), nil
+}
+
+// show writes out the snippet as it was at the revision given as NAME@REV.
+func (m *Manager) show(s string) (string, error) {
+	if m.g == nil {
+		return "", fmt.Errorf("%w: git history is disabled", ErrUnsupported)
+	}
+	s = strings.TrimSpace(s)
+	i := strings.LastIndex(s, "@")
+	if i <= 0 {
+		return "", fmt.Errorf("%w: expected NAME@REV: %s", vcs.ErrBadRev, s)
+	}
+	snip, err := m.at(s[:i], s[i+1:])
+	if err != nil {
+		return "", err
+	}
+	return snip.Repr(), nil
+}
+
+// at finds the snippet in the source file at the revision.
+func (m *Manager) at(name, rev string) (*snippets.Snippet, error) {
+	data, err := m.g.Show(rev)
+	if err != nil {
+		return nil, err
+	}
+	c, err := m.f.Load(bytes.NewReader(data))
+	if err != nil && !errors.Is(err, parsing.ErrEmptyFile) {
+		return nil, err
+	}
+	s, err := c.Find(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w at %s", err, rev)
+	}
+	return &s, nil
+}
+
+// write rewrites the source file with the contents of the container in the
+// format of the file and reloads it.
+func (m *Manager) write() error {
+	snips, err := m.c.ListObj()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	err = m.f.Write(&b, snips)
+	if err != nil {
+		return err
+	}
+	if err := m.backup(); err != nil {
+		return err
+	}
+	start := time.Now()
+	err = m.fh.Truncate(0)
+	m.fh.Write(b.Bytes())
+	if m.metrics != nil {
+		m.metrics.Write(time.Since(start))
+	}
+	return m.reload()
+}
+
+// backup saves a copy of the source file as it is before the rewrite. Empty
+// files are not backed up.
+func (m *Manager) backup() error {
+	if m.b == nil {
+		return nil
+	}
+	if _, err := m.fh.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(m.fh)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := m.b.Save(data); err != nil {
+		return fmt.Errorf("failed to back up the source file: %w", err)
+	}
+	return nil
+}
+
+// guard locks the source file and, if check is set, verifies that it has not
+// been modified since it was loaded. Managers not created with NewManager do
+// not guard the file.
+func (m *Manager) guard(check bool) (func() error, error) {
+	if m.stamp == nil {
+		return func() error { return nil }, nil
+	}
+	unlock, err := m.fh.Lock(lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if !check {
+		return unlock, nil
+	}
+	modified, err := m.fh.Modified(*m.stamp)
+	if err == nil && modified {
+		err = fmt.Errorf(
+			"%w since it was loaded: %s; reload to pick up the changes",
+			fs.ErrModified,
+			m.fh.Name(),
+		)
+	}
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+func (m *Manager) reload() (err error) {
+	if m.metrics != nil {
+		defer func(start time.Time) { m.metrics.Reload(time.Since(start)) }(time.Now())
+	}
+	defer func() { m.loaded, m.loadErr = time.Now(), err }()
+	err = m.fh.Reload()
+	if err != nil {
+		return err
+	}
+	snpts, err := m.f.Load(m.fh)
 	if err != nil && !errors.Is(err, parsing.ErrEmptyFile) {
 		return err
 	}
 	m.c = snpts
+	if m.stamp != nil {
+		st, err := m.fh.Stamp()
+		if err != nil {
+			return err
+		}
+		m.stamp = &st
+	}
 	return nil
 }
@@ -2,12 +2,22 @@ package manager
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mdm-code/gsnip/internal/backup"
 	"github.com/mdm-code/gsnip/internal/fs"
+	"github.com/mdm-code/gsnip/internal/metrics"
 	"github.com/mdm-code/gsnip/internal/parsing"
 	"github.com/mdm-code/gsnip/internal/snippets"
+	"github.com/mdm-code/gsnip/internal/stats"
 	"github.com/mdm-code/gsnip/internal/stream"
+	"github.com/mdm-code/gsnip/internal/vcs"
 )
 
 var c snippets.Container
@@ -28,11 +38,23 @@ func init() {
 	})
 	p = parsing.NewParser()
 	a = map[stream.Opcode]interface{}{
-		stream.Find:   (*Manager).find,
-		stream.Insert: (*Manager).insert,
-		stream.Delete: (*Manager).delete,
-		stream.Reload: (*Manager).reload,
-		stream.List:   (*Manager).list,
+		stream.Find:    (*Manager).find,
+		stream.Insert:  (*Manager).insert,
+		stream.Delete:  (*Manager).delete,
+		stream.Reload:  (*Manager).reload,
+		stream.List:    (*Manager).list,
+		stream.Update:  (*Manager).update,
+		stream.Dump:    (*Manager).dump,
+		stream.Undo:    (*Manager).undo,
+		stream.Redo:    (*Manager).redo,
+		stream.History: (*Manager).history,
+		stream.Backups: (*Manager).backups,
+		stream.Restore: (*Manager).restore,
+		stream.Log:     (*Manager).log,
+		stream.Show:    (*Manager).show,
+		stream.Usage:   (*Manager).usage,
+		stream.Unused:  (*Manager).unused,
+		stream.Rename:  (*Manager).rename,
 	}
 }
 
@@ -51,6 +73,9 @@ func TestProgramAcceptsFindCmd(t *testing.T) {
 	if err != nil || string(rp.Body) != want.Body {
 		t.Error("executing find fails")
 	}
+	if rp.Rev != want.Rev() {
+		t.Errorf("want: %s; has: %s", want.Rev(), rp.Rev)
+	}
 }
 
 func TestProgramAcceptsListCmd(t *testing.T) {
@@ -59,12 +84,12 @@ func TestProgramAcceptsListCmd(t *testing.T) {
 	var rp stream.Reply
 	err := m.Execute(rq, &rp)
 	var want string
-	listing, err := c.List()
+	listing, err := c.ListObj()
 	if err != nil {
 		t.Error("failed to get a list of snippets")
 	}
 	for _, e := range listing {
-		want = want + e + "\n"
+		want = want + e.Name + "\t" + e.Desc + "\t" + e.Rev() + "\n"
 	}
 	if err != nil {
 		t.Error("failed to execute the list command")
@@ -92,6 +117,23 @@ func TestExecuteList(t *testing.T) {
 	}
 }
 
+func TestExecuteDump(t *testing.T) {
+	m := newManager(&fs.FileHandler{}, c, &p, a)
+	result, err := m.dump()
+	if err != nil {
+		t.Fatalf("got %v", err)
+	}
+	parsed, err := p.Parse(strings.NewReader(result))
+	if err != nil {
+		t.Fatalf("failed to parse the dump: %s", err)
+	}
+	has, _ := parsed.ListObj()
+	want, _ := c.ListObj()
+	if !reflect.DeepEqual(has, want) {
+		t.Errorf("want: %v; has: %v", want, has)
+	}
+}
+
 func TestExecuteFind(t *testing.T) {
 	m := newManager(&fs.FileHandler{}, c, &p, a)
 	result, err := m.find("func")
@@ -131,6 +173,45 @@ func TestExecuteInsert(t *testing.T) {
 	}
 }
 
+func TestExecuteInsertBatchIsAtomic(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	m, err := NewManager(fh, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op stream.Opcode, body string) stream.Reply {
+		var rp stream.Reply
+		m.Execute(stream.Request{Operation: op, Body: []byte(body)}, &rp)
+		return rp
+	}
+
+	do(stream.Insert, "startsnip iferr \"\" aliases=ife\nif err != nil {}\nendsnip")
+	do(stream.Insert, "startsnip method \"\"\nbody\nendsnip")
+	// NOTE: Snippets are stored by name, so the alias collision of the last
+	// one is found after the first two are stored
+	batch := "startsnip first \"\"\nfirst\nendsnip\n" +
+		"startsnip method \"replaced\"\nreplaced\nendsnip\n" +
+		"startsnip second \"\" aliases=ife\nsecond\nendsnip"
+	if rp := do(stream.Update, batch); rp.Code != stream.Exists {
+		t.Fatalf("want: %s; has: %s (%s)", stream.Exists, rp.Code, rp.Body)
+	}
+	if rp := do(stream.Find, "first"); rp.Code != stream.NotFound {
+		t.Errorf("a failed batch should store nothing; has: %s (%s)", rp.Code, rp.Body)
+	}
+	if rp := do(stream.Find, "method"); string(rp.Body) != "body" {
+		t.Errorf("a failed batch should replace nothing; has: %s (%s)", rp.Body, rp.Code)
+	}
+	do(stream.Insert, "startsnip other \"\"\nbody\nendsnip")
+	if rp := do(stream.List, ""); strings.Count(string(rp.Body), "\n") != 3 {
+		t.Errorf("a failed batch should not be written later: %q", rp.Body)
+	}
+}
+
 func TestExecuteDelete(t *testing.T) {
 	m := newManager(&fs.FileHandler{}, c, &p, a)
 
@@ -147,3 +228,726 @@ func TestExecuteDelete(t *testing.T) {
 		t.Error("failed to delete a snippet: ", err)
 	}
 }
+
+func TestExecuteReportsCode(t *testing.T) {
+	m := newManager(&fs.FileHandler{}, c, &p, a)
+	data := []struct {
+		name string
+		rq   stream.Request
+		want stream.Code
+	}{
+		{"unsupported", stream.Request{Operation: stream.Undefined}, stream.Unsupported},
+		{"not found", stream.Request{Operation: stream.Find, Body: []byte("missing")}, stream.NotFound},
+		{"invalid", stream.Request{Operation: stream.Insert, Body: []byte("startsnip @LST \"\"\nbody\nendsnip")}, stream.Invalid},
+		{"exists", stream.Request{Operation: stream.Insert, Body: []byte("startsnip method \"\"\nbody\nendsnip")}, stream.Exists},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			var rp stream.Reply
+			err := m.Execute(d.rq, &rp)
+			if err == nil || rp.Result != stream.Failure || rp.Code != d.want {
+				t.Errorf("want: %s; has: %s (%v)", d.want, rp.Code, err)
+			}
+			if string(rp.Body) != err.Error() {
+				t.Errorf("want: %s; has: %s", err, rp.Body)
+			}
+		})
+	}
+}
+
+func TestExecuteUpdate(t *testing.T) {
+	m := newManager(&fs.FileHandler{}, c, &p, a)
+
+	// NOTE: Recover from nil pointer FileHandler.file.Write panic
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println("recovered from:", err)
+		}
+	}()
+
+	_, err := m.update("startsnip method \"replaced\"\nreplaced\nendsnip")
+
+	if err != nil {
+		t.Error("failed to update a snippet: ", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	defer os.Remove(JournalPath(fh.Name()))
+	has := func(caps []string, name string) bool {
+		for _, c := range caps {
+			if c == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	m, err := NewManager(fh, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	caps := m.Capabilities()
+	for _, c := range []string{"find", "insert", "rename", stream.FeatureBatch, stream.FeatureAliases, stream.FeatureRevisions} {
+		if !has(caps, c) {
+			t.Errorf("%s should be supported: %v", c, caps)
+		}
+	}
+	for _, c := range []string{"undo", "history", "backups", "restore", "log", "show", "usage", "unused", "ping"} {
+		if has(caps, c) {
+			t.Errorf("%s should not be supported: %v", c, caps)
+		}
+	}
+
+	m, err = NewManager(fh, Options{History: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps := m.Capabilities(); !has(caps, "undo") || !has(caps, "redo") || !has(caps, "history") {
+		t.Errorf("history should be supported: %v", caps)
+	}
+}
+
+func TestExecuteUndoRedo(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	defer os.Remove(JournalPath(fh.Name()))
+	m, err := NewManager(fh, Options{History: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec := func(op stream.Opcode, body string) (string, error) {
+		var rp stream.Reply
+		err := m.Execute(stream.Request{Operation: op, Body: []byte(body)}, &rp)
+		return string(rp.Body), err
+	}
+	find := func(name string) string {
+		body, _ := exec(stream.Find, name)
+		return body
+	}
+
+	exec(stream.Insert, "startsnip test \"\"\nfirst\nendsnip")
+	exec(stream.Update, "startsnip test \"\"\nsecond\nendsnip")
+	exec(stream.Delete, "test")
+
+	steps := []struct {
+		op   stream.Opcode
+		want string
+	}{
+		{stream.Undo, "second"},
+		{stream.Undo, "first"},
+		{stream.Redo, "second"},
+		{stream.Undo, "first"},
+		{stream.Undo, "snippet was not found: test"},
+		{stream.Redo, "first"},
+	}
+	for i, s := range steps {
+		if _, err := exec(s.op, ""); err != nil {
+			t.Fatalf("step %d: %s", i, err)
+		}
+		if has := find("test"); has != s.want {
+			t.Errorf("step %d: want: %q; has: %q", i, s.want, has)
+		}
+	}
+
+	listing, err := exec(stream.History, "")
+	if err != nil || strings.Count(listing, "\n") != 3 || strings.Count(listing, "(undone)") != 2 {
+		t.Errorf("unexpected history:\n%s", listing)
+	}
+	entry, err := exec(stream.History, "2")
+	if err != nil || !strings.Contains(entry, "--- before\nstartsnip test") {
+		t.Errorf("unexpected entry:\n%s", entry)
+	}
+	var rp stream.Reply
+	m.Execute(stream.Request{Operation: stream.History, Body: []byte("9")}, &rp)
+	if rp.Code != stream.NotFound {
+		t.Errorf("want: %s; has: %s", stream.NotFound, rp.Code)
+	}
+}
+
+func TestExecuteUndoFailureKeepsHistory(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	defer os.Remove(JournalPath(fh.Name()))
+	store := backup.NewStore(t.TempDir(), fh.Name(), 0, 0)
+	m, err := NewManager(fh, Options{History: 10, Backups: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op stream.Opcode, body string) stream.Reply {
+		var rp stream.Reply
+		m.Execute(stream.Request{Operation: op, Body: []byte(body)}, &rp)
+		return rp
+	}
+
+	do(stream.Insert, "startsnip test \"\"\nfirst\nendsnip")
+	do(stream.Update, "startsnip test \"\"\nsecond\nendsnip")
+
+	// NOTE: Backups fail to be saved into a regular file, so does the rewrite
+	dir := store.Dir
+	store.Dir = fh.Name()
+	if rp := do(stream.Undo, ""); rp.Result != stream.Failure {
+		t.Fatalf("undo should fail; has: %s", rp.Body)
+	}
+	if rp := do(stream.Find, "test"); string(rp.Body) != "second" {
+		t.Errorf("a failed undo should change nothing; has: %s", rp.Body)
+	}
+	if rp := do(stream.History, ""); strings.Contains(string(rp.Body), "(undone)") {
+		t.Errorf("a failed undo should not move the history:\n%s", rp.Body)
+	}
+	store.Dir = dir
+	if rp := do(stream.Undo, ""); rp.Code != stream.OK {
+		t.Fatalf("has: %s (%s)", rp.Code, rp.Body)
+	}
+	if rp := do(stream.Find, "test"); string(rp.Body) != "first" {
+		t.Errorf("want: first; has: %s", rp.Body)
+	}
+}
+
+func TestExecuteWriteFailureChangesNothing(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	store := backup.NewStore(t.TempDir(), fh.Name(), 0, 0)
+	m, err := NewManager(fh, Options{Backups: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op stream.Opcode, body string) stream.Reply {
+		var rp stream.Reply
+		m.Execute(stream.Request{Operation: op, Body: []byte(body)}, &rp)
+		return rp
+	}
+
+	do(stream.Insert, "startsnip test \"\"\nfirst\nendsnip")
+	do(stream.Update, "startsnip test \"\"\nsecond\nendsnip")
+	id := strings.Fields(string(do(stream.Backups, "").Body))[0]
+
+	// NOTE: Backups fail to be saved into a regular file, so does the rewrite
+	dir := store.Dir
+	store.Dir = fh.Name()
+	if rp := do(stream.Insert, "startsnip other \"\"\nbody\nendsnip"); rp.Result != stream.Failure {
+		t.Fatalf("insert should fail; has: %s", rp.Body)
+	}
+	if rp := do(stream.Find, "other"); rp.Code != stream.NotFound {
+		t.Errorf("a failed insert should not be found; has: %s", rp.Body)
+	}
+	if rp := do(stream.Update, "startsnip test \"\"\nthird\nendsnip"); rp.Result != stream.Failure {
+		t.Fatalf("update should fail; has: %s", rp.Body)
+	}
+	if rp := do(stream.Delete, "test"); rp.Result != stream.Failure {
+		t.Fatalf("delete should fail; has: %s", rp.Body)
+	}
+	if rp := do(stream.Rename, "test renamed"); rp.Result != stream.Failure {
+		t.Fatalf("rename should fail; has: %s", rp.Body)
+	}
+	if rp := do(stream.Find, "renamed"); rp.Code != stream.NotFound {
+		t.Errorf("a failed rename should not be found; has: %s", rp.Body)
+	}
+	if rp := do(stream.Find, "test"); string(rp.Body) != "second" {
+		t.Errorf("failed requests should change nothing; has: %s", rp.Body)
+	}
+
+	// NOTE: Restoring reads the backup directory, so it has to stay readable
+	// while it is not writable, which does not stop the superuser
+	store.Dir = dir
+	if os.Geteuid() <= 0 {
+		return
+	}
+	os.Chmod(dir, 0500)
+	defer os.Chmod(dir, 0700)
+	if rp := do(stream.Restore, id); rp.Result != stream.Failure {
+		t.Fatalf("restore should fail; has: %s", rp.Body)
+	}
+	if rp := do(stream.Find, "test"); string(rp.Body) != "second" {
+		t.Errorf("a failed restore should change nothing; has: %s", rp.Body)
+	}
+}
+
+func TestExecuteUndoDisabled(t *testing.T) {
+	m := newManager(&fs.FileHandler{}, c, &p, a)
+	var rp stream.Reply
+	m.Execute(stream.Request{Operation: stream.Undo}, &rp)
+	if rp.Code != stream.Unsupported {
+		t.Errorf("want: %s; has: %s", stream.Unsupported, rp.Code)
+	}
+}
+
+func TestExecuteRestore(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	defer os.Remove(JournalPath(fh.Name()))
+	store := backup.NewStore(t.TempDir(), fh.Name(), 0, 0)
+	m, err := NewManager(fh, Options{History: 10, Backups: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exec := func(op stream.Opcode, body string) (string, error) {
+		var rp stream.Reply
+		err := m.Execute(stream.Request{Operation: op, Body: []byte(body)}, &rp)
+		return string(rp.Body), err
+	}
+
+	exec(stream.Insert, "startsnip one \"\"\n1\nendsnip")
+	exec(stream.Insert, "startsnip two \"\"\n2\nendsnip")
+	exec(stream.Delete, "one")
+
+	backups, _ := store.List()
+	if len(backups) != 2 {
+		t.Fatalf("want 2 backups of a non-empty file; has: %v", backups)
+	}
+	listing, err := exec(stream.Backups, "")
+	if err != nil || !strings.HasPrefix(listing, backups[0].ID+"\t") {
+		t.Errorf("unexpected listing: %q (%v)", listing, err)
+	}
+
+	// NOTE: The oldest backup holds the file with the first snippet only
+	if _, err := exec(stream.Restore, backups[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := exec(stream.List, ""); !strings.HasPrefix(has, "one\t\t") || strings.Count(has, "\n") != 1 {
+		t.Errorf("unexpected snippets after restore: %q", has)
+	}
+	if _, err := exec(stream.Undo, ""); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := exec(stream.List, ""); !strings.HasPrefix(has, "two\t\t") || strings.Count(has, "\n") != 1 {
+		t.Errorf("unexpected snippets after undo: %q", has)
+	}
+
+	var rp stream.Reply
+	m.Execute(stream.Request{Operation: stream.Restore, Body: []byte("missing")}, &rp)
+	if rp.Code != stream.NotFound {
+		t.Errorf("want: %s; has: %s", stream.NotFound, rp.Code)
+	}
+}
+
+func TestExecuteLogShow(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	fname := filepath.Join(t.TempDir(), "snippets")
+	fh, err := fs.NewFileHandler(fname, fs.Perm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	repo, err := vcs.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManager(fh, Options{Git: repo})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op stream.Opcode, body string) (string, error) {
+		var rp stream.Reply
+		rq := stream.Request{Operation: op, Body: []byte(body), User: "alice"}
+		err := m.Execute(rq, &rp)
+		return string(rp.Body), err
+	}
+
+	do(stream.Insert, "startsnip one \"\"\n1\nendsnip")
+	do(stream.Insert, "startsnip two \"\"\n2\nendsnip")
+	do(stream.Update, "startsnip one \"\"\nuno\nendsnip")
+
+	log, err := do(stream.Log, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(log), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "gsnip: update one") {
+		t.Fatalf("unexpected log:\n%s", log)
+	}
+	rev, _, _ := strings.Cut(lines[1], "\t")
+	has, err := do(stream.Show, "one@"+rev)
+	if err != nil || has != "startsnip one \"\"\n1\nendsnip\n\n" {
+		t.Errorf("has: %q (%v)", has, err)
+	}
+
+	data := []struct {
+		name, op string
+		opcd     stream.Opcode
+	}{
+		{"unknown snippet", "three", stream.Log},
+		{"missing at revision", "two@" + rev, stream.Show},
+		{"unknown revision", "one@nope", stream.Show},
+		{"no revision", "one", stream.Show},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			var rp stream.Reply
+			m.Execute(stream.Request{Operation: d.opcd, Body: []byte(d.op)}, &rp)
+			if rp.Code != stream.NotFound {
+				t.Errorf("want: %s; has: %s (%s)", stream.NotFound, rp.Code, rp.Body)
+			}
+		})
+	}
+}
+
+func TestExecuteConflict(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "snippets")
+	os.WriteFile(fname, []byte("startsnip one \"\"\n1\nendsnip\n"), 0644)
+	fh, err := fs.NewFileHandler(fname, fs.Perm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	m, err := NewManager(fh, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op stream.Opcode, body string) stream.Reply {
+		var rp stream.Reply
+		m.Execute(stream.Request{Operation: op, Body: []byte(body)}, &rp)
+		return rp
+	}
+
+	if rp := do(stream.Delete, "one"); rp.Code != stream.OK {
+		t.Fatalf("has: %s (%s)", rp.Code, rp.Body)
+	}
+	external := "startsnip two \"\"\n2\nendsnip\n"
+	os.WriteFile(fname, []byte(external), 0644)
+	if rp := do(stream.Insert, "startsnip three \"\"\n3\nendsnip"); rp.Code != stream.Conflict {
+		t.Errorf("want: %s; has: %s (%s)", stream.Conflict, rp.Code, rp.Body)
+	}
+	if data, _ := os.ReadFile(fname); string(data) != external {
+		t.Errorf("external changes were overwritten: %q", data)
+	}
+	if rp := do(stream.Reload, ""); rp.Code != stream.OK {
+		t.Fatalf("has: %s (%s)", rp.Code, rp.Body)
+	}
+	if rp := do(stream.Insert, "startsnip three \"\"\n3\nendsnip"); rp.Code != stream.OK {
+		t.Errorf("has: %s (%s)", rp.Code, rp.Body)
+	}
+	if rp := do(stream.Dump, ""); !strings.HasPrefix(string(rp.Body), "startsnip three") || !strings.Contains(string(rp.Body), "startsnip two") {
+		t.Errorf("has: %q", rp.Body)
+	}
+
+	unlock, err := fh.Lock(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	defer func(d time.Duration) { lockTimeout = d }(lockTimeout)
+	lockTimeout = 100 * time.Millisecond
+	if rp := do(stream.Delete, "two"); rp.Code != stream.Conflict {
+		t.Errorf("want: %s; has: %s (%s)", stream.Conflict, rp.Code, rp.Body)
+	}
+}
+
+func TestReloaded(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "snippets")
+	os.WriteFile(fname, []byte("startsnip one \"\"\n1\nendsnip\n"), 0644)
+	fh, err := fs.NewFileHandler(fname, fs.Perm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	before := time.Now()
+	m, err := NewManager(fh, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at, err := m.Reloaded(); at.Before(before) || err != nil {
+		t.Errorf("the first load should be recorded: %v (%v)", at, err)
+	}
+
+	var rp stream.Reply
+	os.WriteFile(fname, []byte("startsnip one\n1\n"), 0644)
+	m.Execute(stream.Request{Operation: stream.Reload}, &rp)
+	if _, err := m.Reloaded(); err == nil || err.Error() != string(rp.Body) {
+		t.Errorf("want: %s; has: %v", rp.Body, err)
+	}
+	os.WriteFile(fname, []byte("startsnip one \"\"\n1\nendsnip\n"), 0644)
+	m.Execute(stream.Request{Operation: stream.Reload}, &rp)
+	if _, err := m.Reloaded(); err != nil {
+		t.Errorf("a successful reload should clear the error: %v", err)
+	}
+}
+
+func TestExecuteStaleRevision(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	m, err := NewManager(fh, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op stream.Opcode, body, rev string) stream.Reply {
+		var rp stream.Reply
+		m.Execute(stream.Request{Operation: op, Body: []byte(body), Rev: rev}, &rp)
+		return rp
+	}
+
+	do(stream.Insert, "startsnip one \"\"\n1\nendsnip", "")
+	rev := do(stream.Find, "one", "").Rev
+	if rev == "" {
+		t.Fatal("find should return the revision")
+	}
+
+	data := []struct {
+		name, body, rev string
+		opcd            stream.Opcode
+		want            stream.Code
+	}{
+		{"update", "startsnip one \"\"\nuno\nendsnip", rev, stream.Update, stream.OK},
+		{"stale update", "startsnip one \"\"\neins\nendsnip", rev, stream.Update, stream.Conflict},
+		{"unchecked update", "startsnip one \"\"\neins\nendsnip", "", stream.Update, stream.OK},
+		{"many snippets", "startsnip one \"\"\n1\nendsnip\nstartsnip two \"\"\n2\nendsnip", rev, stream.Update, stream.Invalid},
+		{"stale delete", "one", rev, stream.Delete, stream.Conflict},
+		{"missing", "two", rev, stream.Delete, stream.Conflict},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			if rp := do(d.opcd, d.body, d.rev); rp.Code != d.want {
+				t.Errorf("want: %s; has: %s (%s)", d.want, rp.Code, rp.Body)
+			}
+		})
+	}
+
+	rev = do(stream.Find, "one", "").Rev
+	if rp := do(stream.Delete, "one", rev); rp.Code != stream.OK {
+		t.Errorf("has: %s (%s)", rp.Code, rp.Body)
+	}
+}
+
+func TestExecuteUsage(t *testing.T) {
+	u, _ := stats.Open("")
+	cntr, _ := snippets.NewSnippetsContainer("map")
+	cntr.Insert(snippets.Snippet{Name: "func", Body: "body"})
+	cntr.Insert(snippets.Snippet{Name: "method", Body: "body"})
+	m := newManager(&fs.FileHandler{}, cntr, &p, a)
+	m.u = u
+
+	do := func(op stream.Opcode, body string) string {
+		var rp stream.Reply
+		if err := m.Execute(stream.Request{Operation: op, Body: []byte(body)}, &rp); err != nil {
+			t.Fatal(err)
+		}
+		return string(rp.Body)
+	}
+
+	do(stream.Find, "method")
+	if has := do(stream.List, ""); !strings.HasPrefix(has, "method\t") {
+		t.Errorf("the used snippet should be listed first:\n%s", has)
+	}
+	has := do(stream.Usage, "")
+	lines := strings.Split(strings.TrimSpace(has), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "method\t1\t") ||
+		lines[1] != "func\t0\tnever\t0" {
+		t.Errorf("unexpected usage:\n%s", has)
+	}
+}
+
+func TestExecuteUnused(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	defer os.Remove(JournalPath(fh.Name()))
+	u, _ := stats.Open("")
+	m, err := NewManager(fh, Options{History: 10, Stats: u})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op stream.Opcode, body string) stream.Reply {
+		var rp stream.Reply
+		m.Execute(stream.Request{Operation: op, Body: []byte(body)}, &rp)
+		return rp
+	}
+
+	for _, n := range []string{"one", "two", "three"} {
+		do(stream.Insert, "startsnip "+n+" \"\"\nbody\nendsnip")
+	}
+	do(stream.Find, "two")
+
+	// NOTE: Usage has been recorded for a moment only, so snippets never
+	// found are not reported yet
+	if rp := do(stream.Unused, "1h"); rp.Code != stream.OK || len(rp.Body) != 0 {
+		t.Errorf("unexpected unused snippets: %s (%s)", rp.Body, rp.Code)
+	}
+	time.Sleep(20 * time.Millisecond)
+	do(stream.Find, "two")
+	do(stream.Insert, "startsnip four \"\"\nbody\nendsnip")
+	names := func(rp stream.Reply) (result []string) {
+		for _, l := range strings.Split(strings.TrimSpace(string(rp.Body)), "\n") {
+			result = append(result, strings.Split(l, "\t")[0])
+		}
+		return result
+	}
+
+	// NOTE: four was inserted a moment ago, so it is not unused yet however
+	// rarely found
+	rp := do(stream.Unused, "10ms")
+	if has := names(rp); !reflect.DeepEqual(has, []string{"one", "three"}) ||
+		!strings.HasPrefix(string(rp.Body), "one\tnever\t0\t") {
+		t.Errorf("unexpected unused snippets: %q", rp.Body)
+	}
+	if has := names(do(stream.Unused, "10ms 2")); !reflect.DeepEqual(has, []string{"one", "three", "two"}) {
+		t.Errorf("rarely found snippets should be listed: %v", has)
+	}
+	for _, body := range []string{"soon", "10ms many", "10ms -1", ""} {
+		if rp := do(stream.Unused, body); rp.Code != stream.Invalid {
+			t.Errorf("%q: want: %s; has: %s", body, stream.Invalid, rp.Code)
+		}
+	}
+
+	var revs []string
+	for _, l := range strings.Split(strings.TrimSpace(string(rp.Body)), "\n") {
+		f := strings.Split(l, "\t")
+		revs = append(revs, f[len(f)-1])
+	}
+	do(stream.Update, "startsnip three \"\"\nchanged\nendsnip")
+	var drp stream.Reply
+	m.Execute(stream.Request{Operation: stream.Delete, Body: []byte("one three"), Rev: strings.Join(revs, " ")}, &drp)
+	if drp.Code != stream.Conflict {
+		t.Fatalf("a snippet changed since it was listed; has: %s (%s)", drp.Code, drp.Body)
+	}
+	if rp := do(stream.Find, "one"); rp.Code != stream.OK {
+		t.Errorf("nothing should be deleted on conflict; has: %s", rp.Code)
+	}
+	do(stream.Undo, "")
+	m.Execute(stream.Request{Operation: stream.Delete, Body: []byte("one three"), Rev: revs[0]}, &drp)
+	if drp.Code != stream.Invalid {
+		t.Errorf("want: %s; has: %s (%s)", stream.Invalid, drp.Code, drp.Body)
+	}
+	m.Execute(stream.Request{Operation: stream.Delete, Body: []byte("one three four"), Rev: strings.Join(revs, " ")}, &drp)
+	if drp.Code != stream.Invalid {
+		t.Errorf("want: %s; has: %s (%s)", stream.Invalid, drp.Code, drp.Body)
+	}
+	do(stream.Delete, "four")
+
+	if rp := do(stream.Delete, "one three missing"); rp.Code != stream.OK {
+		t.Fatalf("has: %s (%s)", rp.Code, rp.Body)
+	}
+	if rp := do(stream.List, ""); !strings.HasPrefix(string(rp.Body), "two\t") || strings.Count(string(rp.Body), "\n") != 1 {
+		t.Errorf("unexpected snippets: %q", rp.Body)
+	}
+	do(stream.Undo, "")
+	if rp := do(stream.List, ""); strings.Count(string(rp.Body), "\n") != 3 {
+		t.Errorf("a batch delete should be undone at once: %q", rp.Body)
+	}
+}
+
+func TestExecuteAliasesAndRename(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	defer os.Remove(JournalPath(fh.Name()))
+	u, _ := stats.Open("")
+	m, err := NewManager(fh, Options{History: 10, Stats: u})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(op stream.Opcode, body string) stream.Reply {
+		var rp stream.Reply
+		m.Execute(stream.Request{Operation: op, Body: []byte(body)}, &rp)
+		return rp
+	}
+
+	do(stream.Insert, "startsnip iferr \"\" aliases=errchk,ife\nif err != nil {}\nendsnip")
+	if rp := do(stream.Find, "ife"); string(rp.Body) != "if err != nil {}" {
+		t.Errorf("alias should resolve; has: %s (%s)", rp.Body, rp.Code)
+	}
+	if rp := do(stream.List, ""); !strings.HasSuffix(string(rp.Body), "\terrchk,ife\n") {
+		t.Errorf("list should show aliases: %q", rp.Body)
+	}
+	if rp := do(stream.Insert, "startsnip errchk \"\"\nbody\nendsnip"); rp.Code != stream.Exists {
+		t.Errorf("want: %s; has: %s (%s)", stream.Exists, rp.Code, rp.Body)
+	}
+
+	if rp := do(stream.Rename, "iferr checkerr"); rp.Code != stream.OK {
+		t.Fatalf("has: %s (%s)", rp.Code, rp.Body)
+	}
+	if has := u.Get("checkerr"); has.Hits != 1 || u.Get("iferr").Hits != 0 {
+		t.Errorf("usage should follow the rename: %v", has)
+	}
+	if rp := do(stream.Find, "errchk"); string(rp.Body) != "if err != nil {}" {
+		t.Errorf("aliases should survive a rename; has: %s (%s)", rp.Body, rp.Code)
+	}
+	if rp := do(stream.Find, "iferr"); rp.Code != stream.NotFound {
+		t.Errorf("old name should be gone; has: %s", rp.Code)
+	}
+	if rp := do(stream.Rename, "checkerr ife"); rp.Code != stream.Exists {
+		t.Errorf("want: %s; has: %s (%s)", stream.Exists, rp.Code, rp.Body)
+	}
+	if rp := do(stream.Rename, "checkerr"); rp.Code != stream.Invalid {
+		t.Errorf("want: %s; has: %s (%s)", stream.Invalid, rp.Code, rp.Body)
+	}
+	do(stream.Undo, "")
+	if has := u.Get("iferr"); has.Hits != 2 {
+		t.Errorf("usage should follow the undone rename: %v", has)
+	}
+	if rp := do(stream.Find, "iferr"); rp.Code != stream.OK {
+		t.Errorf("undo should restore the old name; has: %s (%s)", rp.Code, rp.Body)
+	}
+	if rp := do(stream.Delete, "ife"); rp.Code != stream.OK {
+		t.Fatalf("has: %s (%s)", rp.Code, rp.Body)
+	}
+	if rp := do(stream.Find, "iferr"); rp.Code != stream.NotFound {
+		t.Errorf("deleting by alias should delete the snippet; has: %s", rp.Code)
+	}
+}
+
+func TestCountAndWriteMetrics(t *testing.T) {
+	fh, err := fs.NewFileHandler("", fs.Temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Remove()
+	mt := metrics.New()
+	m, err := NewManager(fh, Options{Metrics: mt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := m.Count(); n != 0 {
+		t.Errorf("want: 0; has: %d", n)
+	}
+	var rp stream.Reply
+	m.Execute(stream.Request{Operation: stream.Insert, Body: []byte("startsnip a \"\"\nbody\nendsnip")}, &rp)
+	if n := m.Count(); n != 1 {
+		t.Errorf("want: 1; has: %d", n)
+	}
+	var b strings.Builder
+	mt.WriteTo(&b)
+	if !strings.Contains(b.String(), "gsnip_write_duration_seconds_count 1\n") {
+		t.Errorf("the rewrite should be measured:\n%s", b.String())
+	}
+	m.Execute(stream.Request{Operation: stream.Reload}, &rp)
+	b.Reset()
+	mt.WriteTo(&b)
+	if !strings.Contains(b.String(), "gsnip_reloads_total 2\n") {
+		t.Errorf("the reload after the rewrite and the requested one should be measured:\n%s", b.String())
+	}
+}
@@ -0,0 +1,41 @@
+package manager
+
+import (
+	"github.com/mdm-code/gsnip/internal/backup"
+	"github.com/mdm-code/gsnip/internal/config"
+	"github.com/mdm-code/gsnip/internal/parsing"
+	"github.com/mdm-code/gsnip/internal/stats"
+	"github.com/mdm-code/gsnip/internal/vcs"
+)
+
+// Configure creates the options for managing the source file fname as set in
+// the configuration. Usage statistics and the git repository are opened, or
+// created, next to the file.
+func Configure(c config.Config, fname string) (Options, error) {
+	o := Options{History: c.History}
+	if c.Backups > 0 {
+		o.Backups = backup.NewStore(c.BackupDir, fname, c.Backups, c.BackupAge)
+	}
+	if c.Stats {
+		u, err := stats.Open(fname + ".stats")
+		if err != nil {
+			return o, err
+		}
+		o.Stats = u
+	}
+	if c.Git {
+		repo, err := vcs.Open(fname)
+		if err != nil {
+			return o, err
+		}
+		o.Git = repo
+	}
+	if c.Format != "" {
+		f, err := parsing.NewFormat(c.Format)
+		if err != nil {
+			return o, err
+		}
+		o.Format = f
+	}
+	return o, nil
+}
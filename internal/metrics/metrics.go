@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// buckets are the upper bounds, in seconds, of the duration histograms.
+var buckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+// histogram counts durations in cumulative buckets.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	for i, b := range buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Metrics collects counters and histograms describing the work of the server.
+// Snippets, when set, reports the number of snippets stored.
+type Metrics struct {
+	Snippets func() int
+
+	requests map[string]uint64
+	errors   map[string]uint64
+	latency  *histogram
+	reloads  uint64
+	reload   *histogram
+	write    *histogram
+	mu       sync.Mutex
+}
+
+// New creates an empty set of metrics.
+func New() *Metrics {
+	return &Metrics{
+		requests: make(map[string]uint64),
+		errors:   make(map[string]uint64),
+		latency:  newHistogram(),
+		reload:   newHistogram(),
+		write:    newHistogram(),
+	}
+}
+
+// Request records a request for the operation op served in d. Failed requests
+// are also counted by the code of their failure.
+func (m *Metrics) Request(op string, failed bool, code string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[op]++
+	if failed {
+		m.errors[code]++
+	}
+	m.latency.observe(d)
+}
+
+// Reload records a reload of the source file that took d.
+func (m *Metrics) Reload(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reloads++
+	m.reload.observe(d)
+}
+
+// Write records a rewrite of the source file that took d.
+func (m *Metrics) Write(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.write.observe(d)
+}
+
+// WriteTo writes the metrics out in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	// NOTE: The snippet count is taken first so that the callback is not
+	// run while the metrics are locked
+	snippets := -1
+	if m.Snippets != nil {
+		snippets = m.Snippets()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	cw := &countWriter{w: bw}
+	counters(cw, "gsnip_requests_total", "Requests served by operation.", "op", m.requests)
+	counters(cw, "gsnip_request_errors_total", "Failed requests by error code.", "code", m.errors)
+	hist(cw, "gsnip_request_duration_seconds", "Time spent serving requests.", m.latency)
+	if snippets >= 0 {
+		header(cw, "gsnip_snippets", "Snippets stored in the source file.", "gauge")
+		fmt.Fprintf(cw, "gsnip_snippets %d\n", snippets)
+	}
+	header(cw, "gsnip_reloads_total", "Reloads of the source file.", "counter")
+	fmt.Fprintf(cw, "gsnip_reloads_total %d\n", m.reloads)
+	hist(cw, "gsnip_reload_duration_seconds", "Time spent reloading the source file.", m.reload)
+	hist(cw, "gsnip_write_duration_seconds", "Time spent rewriting the source file.", m.write)
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP serves the metrics to Prometheus.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+func header(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func counters(w io.Writer, name, help, label string, values map[string]uint64) {
+	header(w, name, help, "counter")
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", name, label, escape(k), values[k])
+	}
+}
+
+func hist(w io.Writer, name, help string, h *histogram) {
+	header(w, name, help, "histogram")
+	for i, b := range buckets {
+		le := strconv.FormatFloat(b, 'g', -1, 64)
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, le, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes the label value.
+func escape(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+// countWriter counts the bytes written through it.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTo(t *testing.T) {
+	m := New()
+	m.Snippets = func() int { return 7 }
+	m.Request("find", false, "ok", 2*time.Millisecond)
+	m.Request("find", true, "not found", 300*time.Microsecond)
+	m.Request("insert", true, `odd "code"`, 3*time.Second)
+	m.Reload(20 * time.Millisecond)
+	m.Write(time.Millisecond)
+
+	var b bytes.Buffer
+	n, err := m.WriteTo(&b)
+	if err != nil || n != int64(b.Len()) {
+		t.Fatalf("want %d bytes; has: %d (%v)", b.Len(), n, err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE gsnip_requests_total counter\n",
+		`gsnip_requests_total{op="find"} 2` + "\n",
+		`gsnip_requests_total{op="insert"} 1` + "\n",
+		`gsnip_request_errors_total{code="not found"} 1` + "\n",
+		`gsnip_request_errors_total{code="odd \"code\""} 1` + "\n",
+		"# TYPE gsnip_request_duration_seconds histogram\n",
+		`gsnip_request_duration_seconds_bucket{le="0.0005"} 1` + "\n",
+		`gsnip_request_duration_seconds_bucket{le="0.0025"} 2` + "\n",
+		`gsnip_request_duration_seconds_bucket{le="2.5"} 2` + "\n",
+		`gsnip_request_duration_seconds_bucket{le="+Inf"} 3` + "\n",
+		"gsnip_request_duration_seconds_count 3\n",
+		"gsnip_snippets 7\n",
+		"gsnip_reloads_total 1\n",
+		`gsnip_reload_duration_seconds_bucket{le="0.025"} 1` + "\n",
+		`gsnip_write_duration_seconds_bucket{le="0.001"} 1` + "\n",
+		"gsnip_write_duration_seconds_sum 0.001\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `{code="ok"}`) {
+		t.Error("successful requests should not be counted as errors")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	m := New()
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("has content type: %s", ct)
+	}
+	if strings.Contains(rec.Body.String(), "gsnip_snippets") {
+		t.Error("snippet count should be left out without a callback")
+	}
+	if !strings.Contains(rec.Body.String(), "gsnip_reloads_total 0\n") {
+		t.Errorf("has: %s", rec.Body.String())
+	}
+}
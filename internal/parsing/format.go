@@ -0,0 +1,122 @@
+package parsing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+// Format reads and writes snippets stored in a source file.
+type Format interface {
+	Load(io.Reader) (snippets.Container, error)
+	Write(io.Writer, []snippets.Snippet) error
+	Name() string
+}
+
+// NewFormat creates a source file format.
+//
+// Allowed formats (f): gsnip, json, yaml
+func NewFormat(f string) (Format, error) {
+	switch f {
+	case "gsnip":
+		return nativeFormat{}, nil
+	case "json":
+		return jsonFormat{}, nil
+	case "yaml":
+		return yamlFormat{}, nil
+	default:
+		return nil, fmt.Errorf("source format (%s) is not implemented", f)
+	}
+}
+
+// FormatFor picks the format of the source file by its extension: .json
+// files hold JSON, .yaml and .yml files hold YAML, and any other file is
+// written in the gsnip syntax.
+func FormatFor(fname string) Format {
+	switch strings.ToLower(filepath.Ext(fname)) {
+	case ".json":
+		return jsonFormat{}
+	case ".yaml", ".yml":
+		return yamlFormat{}
+	default:
+		return nativeFormat{}
+	}
+}
+
+// nativeFormat is the startsnip/endsnip syntax of gsnip.
+type nativeFormat struct{}
+
+func (nativeFormat) Load(r io.Reader) (snippets.Container, error) {
+	parser := NewParser()
+	return parser.Parse(r)
+}
+
+func (nativeFormat) Write(w io.Writer, snips []snippets.Snippet) error {
+	var b strings.Builder
+	for _, s := range snips {
+		b.WriteString(s.Repr())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (nativeFormat) Name() string { return "gsnip" }
+
+// jsonFormat holds snippets in a JSON array of objects.
+type jsonFormat struct{}
+
+func (jsonFormat) Load(r io.Reader) (snippets.Container, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var snips []snippets.Snippet
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &snips); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrLine, err)
+		}
+	}
+	return load(snips)
+}
+
+func (jsonFormat) Write(w io.Writer, snips []snippets.Snippet) error {
+	if snips == nil {
+		snips = []snippets.Snippet{}
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snips); err != nil {
+		return err
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func (jsonFormat) Name() string { return "json" }
+
+// load puts the decoded snippets into a fresh container following the rules
+// of Parser.Parse.
+func load(snips []snippets.Snippet) (snippets.Container, error) {
+	smap, err := snippets.NewSnippetsContainer("map")
+	if err != nil {
+		return nil, err
+	}
+	if len(snips) == 0 {
+		return smap, fmt.Errorf("%w", ErrEmptyFile)
+	}
+	for _, s := range snips {
+		err = smap.Insert(s)
+		if err != nil && !errors.Is(err, snippets.ErrExists) {
+			return smap, err
+		}
+	}
+	return smap, nil
+}
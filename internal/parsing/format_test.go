@@ -0,0 +1,99 @@
+package parsing
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+var formatSnips = []snippets.Snippet{
+	funcSnip,
+	{
+		Name:  "heredoc",
+		Desc:  `tricky "desc" # not a comment`,
+		Body:  "endsnip\n  indented: value\n\n- item\n",
+		Attrs: map[string]string{"lang": "go", "tags": "a, b"},
+	},
+	{Name: "leading", Desc: "", Body: "\n  leading space\n\n\n"},
+	{Name: "quoted", Desc: "control characters", Body: "bell\a\vend"},
+	{Name: "unicode", Desc: "żółw 🐢", Body: "    "},
+	structSnip,
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	for _, name := range []string{"gsnip", "json", "yaml"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := NewFormat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := f.Write(&buf, formatSnips); err != nil {
+				t.Fatal(err)
+			}
+			c, err := f.Load(&buf)
+			if err != nil {
+				t.Fatalf("failed to load:\n%s\n%s", buf.String(), err)
+			}
+			want, _ := load(formatSnips)
+			if !reflect.DeepEqual(c, want) {
+				t.Errorf("want: %v; has: %v", want, c)
+			}
+		})
+	}
+}
+
+func TestFormatEmpty(t *testing.T) {
+	for _, name := range []string{"gsnip", "json", "yaml"} {
+		t.Run(name, func(t *testing.T) {
+			f, _ := NewFormat(name)
+			var buf bytes.Buffer
+			if err := f.Write(&buf, nil); err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range []string{buf.String(), ""} {
+				if _, err := f.Load(strings.NewReader(r)); !errors.Is(err, ErrEmptyFile) {
+					t.Errorf("want: %v; has: %v", ErrEmptyFile, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatValidates(t *testing.T) {
+	inputs := map[string]string{
+		"json": `[{"name": "@LST", "desc": "", "body": ""}]`,
+		"yaml": "- name: \"@LST\"\n  body: x\n",
+	}
+	for name, i := range inputs {
+		f, _ := NewFormat(name)
+		if _, err := f.Load(strings.NewReader(i)); !errors.Is(err, snippets.ErrReservedName) {
+			t.Errorf("%s: want: %v; has: %v", name, snippets.ErrReservedName, err)
+		}
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	data := map[string]string{
+		"snippets":           "gsnip",
+		"snippets.snip":      "gsnip",
+		"/a/b/snippets.json": "json",
+		"snippets.YAML":      "yaml",
+		"snippets.yml":       "yaml",
+	}
+	for fname, want := range data {
+		if has := FormatFor(fname).Name(); has != want {
+			t.Errorf("%s: want: %s; has: %s", fname, want, has)
+		}
+	}
+}
+
+func TestNewFormatFails(t *testing.T) {
+	if _, err := NewFormat("toml"); err == nil {
+		t.Error("expected an error caused by unknown format")
+	}
+}
@@ -30,12 +30,14 @@ type stateMachine struct {
 	transitions map[state]func(*stateMachine, string) (state, string)
 	parsed      []snippets.Snippet
 	body        []string
+	delim       string
 	state       state
 }
 
 // Parser parses input files with snippets.
 type Parser struct {
 	sm *stateMachine
+	v  snippets.Validator
 }
 
 func newStateMachine() *stateMachine {
@@ -49,17 +51,28 @@ func newStateMachine() *stateMachine {
 	}
 }
 
-// NewParser creates a new parser.
+// NewParser creates a new parser that checks parsed snippets against the
+// snippets.DefaultValidator.
 func NewParser() Parser {
+	return NewValidatedParser(snippets.DefaultValidator)
+}
+
+// NewValidatedParser creates a new parser that checks parsed snippets against
+// the validator v.
+func NewValidatedParser(v snippets.Validator) Parser {
 	return Parser{
 		sm: newStateMachine(),
+		v:  v,
 	}
 }
 
 // Parse parses file with snippets. The result is a map
 // of of snippets with name as key and body as value.
+//
+// Snippets breaking the rules of the validator cause Parse to fail. When the
+// name is repeated, the first snippet with that name is kept.
 func (p *Parser) Parse(i io.Reader) (snippets.Container, error) {
-	smap, err := snippets.NewSnippetsContainer("map")
+	smap, err := snippets.NewValidatedContainer("map", p.v)
 	if err != nil {
 		return nil, err
 	}
@@ -68,7 +81,10 @@ func (p *Parser) Parse(i io.Reader) (snippets.Container, error) {
 		return smap, err
 	}
 	for _, s := range parsed {
-		smap.Insert(s)
+		err = smap.Insert(s)
+		if err != nil && !errors.Is(err, snippets.ErrExists) {
+			return smap, err
+		}
 	}
 	return smap, nil
 }
@@ -87,58 +103,38 @@ func (sm *stateMachine) scanLine(line string) (state, string) {
 }
 
 func (sm *stateMachine) readSignature(line string) (state, string) {
-	elems, ok := splitSignature(line)
+	sig, ok := splitSignature(line)
 	if !ok {
 		return errored, line
 	}
-	snip := snippets.Snippet{Name: elems[0], Desc: elems[1]}
+	snip := snippets.Snippet{Name: sig.name, Desc: sig.desc, Attrs: sig.attrs}
 	sm.parsed = append(sm.parsed, snip)
+	sm.delim = sig.delim
 	return scanBody, ""
 }
 
-func splitSignature(s string) ([]string, bool) {
-	var startToken, name, comment string
-	splits := strings.SplitN(s, " ", 3)
-	unpack(splits, &startToken, &name, &comment)
-	comment, ok := takeBetween(comment, '"')
-	if !ok {
-		return nil, false
-	}
-	return []string{name, comment}, true
-}
-
-func unpack(s []string, vars ...*string) {
-	for i, str := range s {
-		*vars[i] = str
-	}
-}
-
-// Grab text between two delimiters.
-func takeBetween(s string, delim rune) (string, bool) {
-	var idxs []int
-	var result string
-	for i, c := range s {
-		if c == delim {
-			idxs = append(idxs, i)
-		}
-	}
-	if len(idxs) < 2 {
-		return result, false
-	}
-	result = s[idxs[0]+1 : idxs[len(idxs)-1]]
-	return result, true
-}
-
 func (sm *stateMachine) scanBody(line string) (state, string) {
-	if l := strings.TrimSpace(line); strings.HasPrefix(l, "endsnip") {
+	if sm.isEnd(line) {
 		sm.parsed[len(sm.parsed)-1].Body = strings.Join(sm.body, "\n")
 		sm.body = sm.body[:0]
+		sm.delim = ""
 		return scanning, ""
 	}
 	sm.body = append(sm.body, line)
 	return scanBody, ""
 }
 
+// isEnd reports whether the line closes the body of the current snippet. A
+// snippet declared with a heredoc delimiter ends only on a line holding the
+// delimiter alone, so its body may contain lines starting with `endsnip`.
+func (sm *stateMachine) isEnd(line string) bool {
+	l := strings.TrimSpace(line)
+	if sm.delim != "" {
+		return l == sm.delim
+	}
+	return strings.HasPrefix(l, "endsnip")
+}
+
 func (sm *stateMachine) run(f io.Reader) ([]snippets.Snippet, error) {
 	s := bufio.NewScanner(f)
 	sm.reset()
@@ -167,5 +163,6 @@ func (sm *stateMachine) run(f io.Reader) ([]snippets.Snippet, error) {
 func (sm *stateMachine) reset() {
 	sm.parsed = nil
 	sm.body = nil
+	sm.delim = ""
 	sm.state = scanning
 }
@@ -92,8 +92,9 @@ func TestAssertReadersFail(t *testing.T) {
 
 func TestSignatureSplitFails(t *testing.T) {
 	inputs := []string{
-		"startsnip struct",                // Missing comment
-		"startsnip printf 'some comment'", // comment not in double quotes
+		"startsnip struct",                  // Missing comment
+		"startsnip printf 'some comment'",   // comment not in double quotes
+		"startsnip doc \"gsnip syntax\" <<", // missing heredoc delimiter
 		"",
 	}
 	for _, i := range inputs {
@@ -108,7 +109,8 @@ func TestSignatureSplitPasses(t *testing.T) {
 	inputs := []string{
 		"startsnip struct \"Go struct snippet\"",
 		"	  startsnip struct \"sample comment\"   ", // whitespace on both sides
-		"startsnip func() \"\"", // Empty comment
+		"startsnip func() \"\"",                     // Empty comment
+		"startsnip doc \"gsnip syntax\" <<END",      // heredoc delimiter
 	}
 	for _, i := range inputs {
 		_, ok := splitSignature(i)
@@ -118,43 +120,6 @@ func TestSignatureSplitPasses(t *testing.T) {
 	}
 }
 
-func TestTakeBetweenPasses(t *testing.T) {
-	inputs := []struct {
-		text, want string
-		delim      rune
-	}{
-		{"\"This text works just fine\"", "This text works just fine", '"'},
-		{"`What about using ticks?`", "What about using ticks?", '`'},
-		{"'Three single ' quotes return the longest'", "Three single ' quotes return the longest", '\''},
-	}
-	for _, i := range inputs {
-		has, ok := takeBetween(i.text, i.delim)
-		if !ok {
-			t.Errorf("String :: %s :: is malformed", i.text)
-		}
-		if has != i.want {
-			t.Errorf("Want: %s; has %s", i.want, has)
-		}
-	}
-}
-
-func TestTakeBetweenFails(t *testing.T) {
-	inputs := []struct {
-		text  string
-		delim rune
-	}{
-		{"This has no delimiters", '"'},
-		{"", '`'},
-		{"' Has only one delimiter", '\''},
-	}
-	for _, i := range inputs {
-		_, ok := takeBetween(i.text, i.delim)
-		if ok {
-			t.Errorf("Input :: %s :: should error out", i.text)
-		}
-	}
-}
-
 func TestParserRun(t *testing.T) {
 	parser := NewParser()
 	for _, r := range failingFileReaders {
@@ -184,3 +149,67 @@ endsnip`))
 		t.Errorf("expected parser to raise %v", ErrLine)
 	}
 }
+
+func TestParserHeredoc(t *testing.T) {
+	parser := NewParser()
+	snips, err := parser.run(strings.NewReader(`startsnip heredoc "snippet about snippets" <<END
+startsnip nested "nested snippet"
+endsnip
+END
+
+startsnip after "regular snippet"
+body
+endsnip`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []snippets.Snippet{
+		{Name: "heredoc", Desc: "snippet about snippets", Body: "startsnip nested \"nested snippet\"\nendsnip"},
+		{Name: "after", Desc: "regular snippet", Body: "body"},
+	}
+	if !reflect.DeepEqual(snips, want) {
+		t.Errorf("want: %v; has %v", want, snips)
+	}
+}
+
+func TestParserReprRoundTrip(t *testing.T) {
+	inputs := []snippets.Snippet{
+		funcSnip,
+		{Name: "heredoc", Desc: "cat << EOF", Body: "cat <<EOF\nendsnip\nEOF"},
+		{Name: "clash", Desc: "delimiter in body", Body: "endsnip\nEND\n\tEND1"},
+	}
+	for _, i := range inputs {
+		parser := NewParser()
+		has, err := parser.run(strings.NewReader(i.Repr()))
+		if err != nil {
+			t.Fatalf("failed to parse %q: %s", i.Repr(), err)
+		}
+		if len(has) != 1 || !reflect.DeepEqual(has[0], i) {
+			t.Errorf("want: %v; has %v", i, has)
+		}
+	}
+}
+
+func TestParserValidates(t *testing.T) {
+	parser := NewParser()
+	_, err := parser.Parse(strings.NewReader("startsnip @LST \"reserved\"\nbody\nendsnip"))
+	if !errors.Is(err, snippets.ErrReservedName) {
+		t.Errorf("want: %v; has: %v", snippets.ErrReservedName, err)
+	}
+}
+
+func TestParserAliases(t *testing.T) {
+	parser := NewParser()
+	src := "startsnip iferr \"check error\" aliases=errchk,ife\nif err != nil {}\nendsnip\n"
+	c, err := parser.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := c.Find("ife"); err != nil || s.Name != "iferr" {
+		t.Errorf("alias should resolve to iferr; has: %v (%v)", s, err)
+	}
+	_, err = parser.Parse(strings.NewReader(src + "startsnip ife \"\"\nbody\nendsnip\n"))
+	if !errors.Is(err, snippets.ErrAliasTaken) {
+		t.Errorf("want: %v; has: %v", snippets.ErrAliasTaken, err)
+	}
+}
@@ -0,0 +1,159 @@
+package parsing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrSignature is raised when the signature line cannot be tokenized.
+	ErrSignature = errors.New("malformed signature")
+)
+
+// token is a single whitespace-delimited word of the signature line.
+type token struct {
+	text   string
+	quoted bool // the token opened with a double quote
+}
+
+// header holds the elements declared on the `startsnip` line.
+type header struct {
+	name  string
+	desc  string
+	attrs map[string]string
+	delim string
+}
+
+// splitSignature breaks the signature line into its elements.
+//
+// The line has the form:
+//
+//	startsnip NAME "COMMENT" [key=value ...] [<<DELIM]
+//
+// Elements are separated by arbitrary white space. Quoted text accepts the
+// \" \\ \n and \t backslash escapes; attribute values may be quoted too.
+func splitSignature(s string) (header, bool) {
+	sig, err := parseSignature(s)
+	return sig, err == nil
+}
+
+func parseSignature(s string) (header, error) {
+	var sig header
+	tokens, err := tokenize(s)
+	if err != nil {
+		return sig, err
+	}
+	if len(tokens) < 3 {
+		return sig, fmt.Errorf("%w: expected a name and a comment", ErrSignature)
+	}
+	if tokens[0].quoted || tokens[0].text != "startsnip" {
+		return sig, fmt.Errorf("%w: expected startsnip", ErrSignature)
+	}
+	if tokens[1].quoted || tokens[1].text == "" {
+		return sig, fmt.Errorf("%w: expected a bare name", ErrSignature)
+	}
+	if !tokens[2].quoted {
+		return sig, fmt.Errorf("%w: comment must be enclosed in double quotes", ErrSignature)
+	}
+	sig.name, sig.desc = tokens[1].text, tokens[2].text
+
+	for i, t := range tokens[3:] {
+		switch {
+		case !t.quoted && strings.HasPrefix(t.text, "<<"):
+			sig.delim = t.text[2:]
+			if sig.delim == "" || i != len(tokens)-4 {
+				return sig, fmt.Errorf("%w: heredoc delimiter must close the line", ErrSignature)
+			}
+		case !t.quoted && strings.Contains(t.text, "="):
+			key, val, _ := strings.Cut(t.text, "=")
+			if !isAttrKey(key) {
+				return sig, fmt.Errorf("%w: invalid attribute key %q", ErrSignature, key)
+			}
+			if _, dup := sig.attrs[key]; dup {
+				return sig, fmt.Errorf("%w: duplicate attribute %q", ErrSignature, key)
+			}
+			if sig.attrs == nil {
+				sig.attrs = make(map[string]string)
+			}
+			sig.attrs[key] = val
+		default:
+			return sig, fmt.Errorf("%w: unexpected token %q", ErrSignature, t.text)
+		}
+	}
+	return sig, nil
+}
+
+// tokenize splits the line into words separated by white space. Double
+// quotes group white space into a single word and may appear anywhere in the
+// word, as in key="some value".
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		if unicode.IsSpace(rs[i]) {
+			i++
+			continue
+		}
+		var b strings.Builder
+		t := token{quoted: rs[i] == '"'}
+		for i < len(rs) && !unicode.IsSpace(rs[i]) {
+			if rs[i] != '"' {
+				b.WriteRune(rs[i])
+				i++
+				continue
+			}
+			end, err := unquote(rs, i, &b)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		}
+		t.text = b.String()
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// unquote writes out the quoted text starting at the opening quote at
+// position start and returns the position right after the closing quote.
+func unquote(rs []rune, start int, b *strings.Builder) (int, error) {
+	for i := start + 1; i < len(rs); i++ {
+		switch rs[i] {
+		case '"':
+			return i + 1, nil
+		case '\\':
+			if i+1 == len(rs) {
+				break
+			}
+			i++
+			switch rs[i] {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case '"', '\\':
+				b.WriteRune(rs[i])
+			default:
+				b.WriteRune('\\')
+				b.WriteRune(rs[i])
+			}
+		default:
+			b.WriteRune(rs[i])
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated quote", ErrSignature)
+}
+
+func isAttrKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package parsing
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+func TestTokenize(t *testing.T) {
+	data := []struct {
+		name string
+		line string
+		want []token
+	}{
+		{
+			"spaces",
+			"startsnip   func  \"desc\"",
+			[]token{{"startsnip", false}, {"func", false}, {"desc", true}},
+		},
+		{
+			"tabs",
+			"\tstartsnip\tfunc\t\"desc\"\t",
+			[]token{{"startsnip", false}, {"func", false}, {"desc", true}},
+		},
+		{
+			"escapes",
+			`startsnip q "say \"hi\"\tnow \\ \x"`,
+			[]token{{"startsnip", false}, {"q", false}, {"say \"hi\"\tnow \\ \\x", true}},
+		},
+		{
+			"attribute",
+			`startsnip q "" lang=go tags="a b"`,
+			[]token{
+				{"startsnip", false},
+				{"q", false},
+				{"", true},
+				{"lang=go", false},
+				{"tags=a b", false},
+			},
+		},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			has, err := tokenize(d.line)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(has, d.want) {
+				t.Errorf("want: %v; has %v", d.want, has)
+			}
+		})
+	}
+}
+
+func TestTokenizeFails(t *testing.T) {
+	inputs := []string{
+		`startsnip func "unterminated`,
+		`startsnip func "escaped quote\"`,
+	}
+	for _, i := range inputs {
+		if _, err := tokenize(i); !errors.Is(err, ErrSignature) {
+			t.Errorf("expected %v for %s", ErrSignature, i)
+		}
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	has, err := parseSignature(`startsnip	iferr  "if err \"!=\" nil" lang=go tags="err handling" <<END`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := header{
+		name:  "iferr",
+		desc:  `if err "!=" nil`,
+		attrs: map[string]string{"lang": "go", "tags": "err handling"},
+		delim: "END",
+	}
+	if !reflect.DeepEqual(has, want) {
+		t.Errorf("want: %v; has %v", want, has)
+	}
+}
+
+func TestParseSignatureFails(t *testing.T) {
+	inputs := []string{
+		`startsnip func "desc" stray`,
+		`startsnip func "desc" <<END lang=go`,
+		`startsnip func "desc" lang=go lang=c`,
+		`startsnip func "desc" =go`,
+		`startsnip func "desc" "extra"`,
+		`startsnipper func "desc"`,
+		`startsnip "func" "desc"`,
+	}
+	for _, i := range inputs {
+		if _, err := parseSignature(i); !errors.Is(err, ErrSignature) {
+			t.Errorf("expected %v for %s", ErrSignature, i)
+		}
+	}
+}
+
+func TestSignatureReprRoundTrip(t *testing.T) {
+	inputs := []snippets.Snippet{
+		{Name: "quotes", Desc: `"quoted" \ backslash`, Body: "body"},
+		{Name: "ws", Desc: "tab\tand\nnewline", Body: "body"},
+		{
+			Name:  "attrs",
+			Desc:  "with attributes",
+			Body:  "endsnip",
+			Attrs: map[string]string{"lang": "go", "empty": "", "tags": `a "b" c`, "doc": "<<END"},
+		},
+	}
+	for _, i := range inputs {
+		parser := NewParser()
+		has, err := parser.run(strings.NewReader(i.Repr()))
+		if err != nil {
+			t.Fatalf("failed to parse %q: %s", i.Repr(), err)
+		}
+		if len(has) != 1 || !reflect.DeepEqual(has[0], i) {
+			t.Errorf("want: %v; has %v", i, has)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package parsing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+// yamlFormat holds snippets in a YAML sequence of mappings:
+//
+//	# snippets.yaml
+//	- name: func
+//	  desc: "Go function"
+//	  attrs:
+//	    lang: go
+//	  body: |-
+//	    func ${1:name}() {
+//	    }
+//
+// It understands the subset of YAML needed for the layout above: plain,
+// single- and double-quoted scalars, literal and folded block scalars, and
+// comments.
+type yamlFormat struct{}
+
+func (yamlFormat) Load(r io.Reader) (snippets.Container, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	// NOTE: The final line break ends the last line rather than starting an
+	// empty one, which kept block scalars would count as a blank line
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	y := yamlReader{lines: lines}
+	snips, err := y.read()
+	if err != nil {
+		return nil, err
+	}
+	return load(snips)
+}
+
+// Write writes snippets out with bodies held in literal block scalars
+// whenever YAML allows it.
+func (yamlFormat) Write(w io.Writer, snips []snippets.Snippet) error {
+	bw := bufio.NewWriter(w)
+	if len(snips) == 0 {
+		bw.WriteString("[]\n")
+	}
+	for _, s := range snips {
+		fmt.Fprintf(bw, "- name: %s\n", yamlString(s.Name))
+		fmt.Fprintf(bw, "  desc: %s\n", yamlString(s.Desc))
+		if len(s.Attrs) > 0 {
+			bw.WriteString("  attrs:\n")
+			keys := make([]string, 0, len(s.Attrs))
+			for k := range s.Attrs {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(bw, "    %s: %s\n", yamlString(k), yamlString(s.Attrs[k]))
+			}
+		}
+		fmt.Fprintf(bw, "  body:%s\n", yamlBlock(s.Body, 2, 2))
+	}
+	return bw.Flush()
+}
+
+func (yamlFormat) Name() string { return "yaml" }
+
+// yamlString writes the text out as a double-quoted scalar. YAML
+// double-quoted scalars accept the JSON string escapes.
+func yamlString(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// yamlBlock writes the text out as a literal block scalar of the key
+// indented with indent, or falls back to a double-quoted scalar for text that
+// a block scalar cannot hold. The block is indented further by step.
+func yamlBlock(s string, indent, step int) string {
+	if s == "" || strings.TrimSpace(s) == "" || !yamlPrintable(s) {
+		return " " + yamlString(s)
+	}
+	header := "|"
+	if first := strings.TrimLeft(s, "\n"); first[0] == ' ' || first[0] == '\t' || first != s {
+		header += fmt.Sprint(step)
+	}
+	trimmed := strings.TrimRight(s, "\n")
+	switch len(s) - len(trimmed) {
+	case 0:
+		header += "-"
+	case 1:
+	default:
+		header += "+"
+	}
+	var b strings.Builder
+	b.WriteString(" " + header)
+	prefix := strings.Repeat(" ", indent+step)
+	for _, l := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		b.WriteString("\n")
+		if l != "" {
+			b.WriteString(prefix + l)
+		}
+	}
+	return b.String()
+}
+
+// yamlPrintable reports whether the text can be held in a block scalar
+// without any of its characters being escaped or read as a line break.
+func yamlPrintable(s string) bool {
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n':
+		case r < 0x20 || r == 0x7f || r >= 0x80 && r < 0xa0:
+			return false
+		case r == '\u2028' || r == '\u2029' || r == '\ufeff' || r >= 0xfffe && r <= 0xffff:
+			return false
+		}
+	}
+	return true
+}
+
+// yamlReader reads the snippet sequence line by line.
+type yamlReader struct {
+	lines []string
+	pos   int
+}
+
+func (y *yamlReader) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrLine, y.pos+1, fmt.Sprintf(format, a...))
+}
+
+func (y *yamlReader) read() ([]snippets.Snippet, error) {
+	var result []snippets.Snippet
+	for y.skipBlank(); y.pos < len(y.lines); y.skipBlank() {
+		line := y.lines[y.pos]
+		if strings.TrimSpace(stripComment(line)) == "[]" && result == nil {
+			y.pos++
+			continue
+		}
+		if !strings.HasPrefix(line, "- ") && strings.TrimRight(line, " ") != "-" {
+			return nil, y.errorf("expected a sequence item")
+		}
+		// NOTE: turn the dash into white space so that the first key lines up
+		// with the remaining keys of the item
+		if strings.HasPrefix(line, "- ") {
+			y.lines[y.pos] = "  " + line[2:]
+		} else {
+			y.lines[y.pos] = ""
+		}
+		snip, err := y.readSnippet(2)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, snip)
+	}
+	return result, nil
+}
+
+func (y *yamlReader) readSnippet(indent int) (snippets.Snippet, error) {
+	var s snippets.Snippet
+	seen := make(map[string]bool)
+	for y.skipBlank(); y.pos < len(y.lines) && indentOf(y.lines[y.pos]) == indent; y.skipBlank() {
+		key, value, err := y.keyValue(indent)
+		if err != nil {
+			return s, err
+		}
+		if seen[key] {
+			return s, y.errorf("duplicate key %q", key)
+		}
+		seen[key] = true
+		switch key {
+		case "name", "desc", "body":
+			text, err := y.scalar(value, indent)
+			if err != nil {
+				return s, err
+			}
+			switch key {
+			case "name":
+				s.Name = text
+			case "desc":
+				s.Desc = text
+			case "body":
+				s.Body = text
+			}
+		case "attrs":
+			if s.Attrs, err = y.readAttrs(value, indent); err != nil {
+				return s, err
+			}
+		default:
+			return s, y.errorf("unknown key %q", key)
+		}
+	}
+	if y.pos < len(y.lines) && indentOf(y.lines[y.pos]) > 0 {
+		return s, y.errorf("unexpected indentation")
+	}
+	return s, nil
+}
+
+func (y *yamlReader) readAttrs(value string, indent int) (map[string]string, error) {
+	if value == "{}" {
+		y.pos++
+		return nil, nil
+	}
+	if value != "" {
+		return nil, y.errorf("expected a mapping of attributes")
+	}
+	y.pos++
+	y.skipBlank()
+	if y.pos == len(y.lines) || indentOf(y.lines[y.pos]) <= indent {
+		return nil, nil
+	}
+	attrs := make(map[string]string)
+	inner := indentOf(y.lines[y.pos])
+	for ; y.pos < len(y.lines) && indentOf(y.lines[y.pos]) == inner; y.skipBlank() {
+		key, value, err := y.keyValue(inner)
+		if err != nil {
+			return nil, err
+		}
+		text, err := y.scalar(value, inner)
+		if err != nil {
+			return nil, err
+		}
+		attrs[key] = text
+	}
+	return attrs, nil
+}
+
+// keyValue splits the current line into the key and the raw value.
+func (y *yamlReader) keyValue(indent int) (string, string, error) {
+	line := y.lines[y.pos][indent:]
+	var key, rest string
+	if strings.HasPrefix(line, `"`) || strings.HasPrefix(line, "'") {
+		k, n, err := quoted(line)
+		if err != nil {
+			return "", "", y.errorf("%s", err)
+		}
+		if !strings.HasPrefix(line[n:], ":") {
+			return "", "", y.errorf("expected a colon after the key")
+		}
+		key, rest = k, line[n+1:]
+	} else {
+		i := strings.Index(line, ":")
+		if i < 0 || i+1 < len(line) && line[i+1] != ' ' {
+			return "", "", y.errorf("expected a key: value pair")
+		}
+		key, rest = strings.TrimSpace(line[:i]), line[i+1:]
+	}
+	if rest != "" && rest[0] != ' ' {
+		return "", "", y.errorf("expected white space after the colon")
+	}
+	return key, strings.TrimSpace(stripComment(rest)), nil
+}
+
+// scalar reads the scalar value of the key on the current line and moves
+// past all the lines that the scalar spans.
+func (y *yamlReader) scalar(value string, indent int) (string, error) {
+	y.pos++
+	switch {
+	case value == "":
+		return "", nil
+	case value[0] == '|' || value[0] == '>':
+		return y.block(value, indent)
+	case value[0] == '"' || value[0] == '\'':
+		text, n, err := quoted(value)
+		if err != nil {
+			y.pos--
+			return "", y.errorf("%s", err)
+		}
+		if strings.TrimSpace(value[n:]) != "" {
+			y.pos--
+			return "", y.errorf("unexpected text after the quoted scalar")
+		}
+		return text, nil
+	default:
+		return value, nil
+	}
+}
+
+// block reads the block scalar following the header on the previous line.
+func (y *yamlReader) block(header string, indent int) (string, error) {
+	folded := header[0] == '>'
+	chomp, blockIndent := byte(0), 0
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = c
+		case c >= '1' && c <= '9' && blockIndent == 0:
+			blockIndent = indent + int(c-'0')
+		default:
+			y.pos--
+			return "", y.errorf("malformed block scalar header %q", header)
+		}
+	}
+
+	var lines []string
+	for ; y.pos < len(y.lines); y.pos++ {
+		line := y.lines[y.pos]
+		if strings.TrimSpace(line) == "" {
+			if len(line) > blockIndent && blockIndent > 0 {
+				lines = append(lines, line[blockIndent:])
+			} else {
+				lines = append(lines, "")
+			}
+			continue
+		}
+		n := indentOf(line)
+		if blockIndent == 0 {
+			blockIndent = n
+		}
+		if n < blockIndent || n <= indent {
+			break
+		}
+		lines = append(lines, line[blockIndent:])
+	}
+
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	content := lines[:len(lines)-trailing]
+	if trailing > 0 {
+		// NOTE: leave the blank lines to the next key unless they are kept
+		y.pos -= trailing
+		if chomp == '+' {
+			y.pos += trailing
+		}
+	}
+
+	var text string
+	if folded {
+		text = fold(content)
+	} else {
+		text = strings.Join(content, "\n")
+	}
+	switch {
+	case chomp == '-':
+		return text, nil
+	case chomp == '+':
+		if len(content) == 0 {
+			return strings.Repeat("\n", trailing), nil
+		}
+		return text + "\n" + strings.Repeat("\n", trailing), nil
+	case len(content) == 0:
+		return "", nil
+	default:
+		return text + "\n", nil
+	}
+}
+
+// fold joins adjacent lines of a folded block scalar with a space. The line
+// break preceding an empty line is dropped, and the ones around more indented
+// lines are kept.
+func fold(lines []string) string {
+	normal := func(l string) bool {
+		return l != "" && l[0] != ' ' && l[0] != '\t'
+	}
+	var b strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case normal(prev) && normal(l):
+				b.WriteString(" ")
+			case normal(prev) && l == "":
+			default:
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(l)
+	}
+	return b.String()
+}
+
+// skipBlank moves past empty lines and comment lines.
+func (y *yamlReader) skipBlank() {
+	for y.pos < len(y.lines) {
+		l := strings.TrimSpace(y.lines[y.pos])
+		if l != "" && !strings.HasPrefix(l, "#") {
+			return
+		}
+		y.pos++
+	}
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// stripComment drops the comment from the end of a line holding a plain or
+// quoted scalar.
+func stripComment(s string) string {
+	inSingle, inDouble := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && inDouble:
+			i++
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '#' && !inSingle && !inDouble && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// quoted reads the single- or double-quoted scalar at the start of s. It
+// returns the text and the number of bytes consumed.
+func quoted(s string) (string, int, error) {
+	var b strings.Builder
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case q == '\'' && c == '\'':
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		case q == '"' && c == '"':
+			return b.String(), i + 1, nil
+		case q == '"' && c == '\\':
+			n, err := unescape(s[i+1:], &b)
+			if err != nil {
+				return "", 0, err
+			}
+			i += n
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted scalar")
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': `"`,
+	'/': "/", '\\': `\`, 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// unescape writes out the escape sequence that follows a backslash and
+// returns the number of bytes it spans.
+func unescape(s string, b *strings.Builder) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("unterminated escape sequence")
+	}
+	if e, ok := yamlEscapes[s[0]]; ok {
+		b.WriteString(e)
+		return 1, nil
+	}
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[0]]
+	if size == 0 || len(s) < size+1 {
+		return 0, fmt.Errorf("invalid escape sequence \\%c", s[0])
+	}
+	r, err := strconv.ParseUint(s[1:size+1], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid escape sequence \\%s", s[:size+1])
+	}
+	// NOTE: JSON encodes characters outside of the BMP as surrogate pairs
+	if r >= 0xd800 && r < 0xdc00 && len(s) >= 2*size+3 && s[size+1:size+3] == `\u` {
+		lo, err := strconv.ParseUint(s[size+3:2*size+3], 16, 32)
+		if err == nil && lo >= 0xdc00 && lo < 0xe000 {
+			b.WriteRune(rune((r-0xd800)<<10 + (lo - 0xdc00) + 0x10000))
+			return 2*size + 3, nil
+		}
+	}
+	if !utf8.ValidRune(rune(r)) {
+		return 0, fmt.Errorf("invalid escape sequence \\%s", s[:size+1])
+	}
+	b.WriteRune(rune(r))
+	return size + 1, nil
+}
@@ -0,0 +1,164 @@
+package parsing
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mdm-code/gsnip/internal/snippets"
+)
+
+func TestYAMLLoad(t *testing.T) {
+	input := `# Handwritten snippets
+- name: func   # trailing comment
+  desc: 'Go function, it''s simple'
+  attrs:
+    lang: go
+    tags: "basics"
+  body: |
+    func ${1:name}() {
+    	return
+    }
+
+-
+  name: folded
+  desc: "tab\there é \U0001F422 🐢"
+  attrs: {}
+  body: >-
+    one
+    line
+
+    more
+      indented
+
+- name: kept
+  body: |+
+    text
+
+
+- name: plain
+  desc: a: b # c
+  body: plain text
+`
+	f, _ := NewFormat("yaml")
+	c, err := f.Load(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	has, _ := c.ListObj()
+	want := []snippets.Snippet{
+		{Name: "folded", Desc: "tab\there é 🐢 🐢", Body: "one line\nmore\n  indented"},
+		{
+			Name:  "func",
+			Desc:  "Go function, it's simple",
+			Body:  "func ${1:name}() {\n\treturn\n}\n",
+			Attrs: map[string]string{"lang": "go", "tags": "basics"},
+		},
+		{Name: "kept", Body: "text\n\n\n"},
+		{Name: "plain", Desc: "a: b", Body: "plain text"},
+	}
+	if !reflect.DeepEqual(has, want) {
+		t.Errorf("want: %q; has: %q", want, has)
+	}
+}
+
+func TestYAMLLoadFails(t *testing.T) {
+	inputs := []string{
+		"name: no sequence",
+		"- name: x\n  name: y",
+		"- name: x\n  unknown: y",
+		"- name: \"unterminated",
+		"- name: \"bad \\q escape\"",
+		"- name: x\n    misaligned: y",
+		"- name: x\n  body: |x\n    text",
+		"- name:x",
+		"- name: \"x\" trailing",
+		"- attrs: value",
+	}
+	f, _ := NewFormat("yaml")
+	for _, i := range inputs {
+		if _, err := f.Load(strings.NewReader(i)); !errors.Is(err, ErrLine) {
+			t.Errorf("want: %v for %q; has: %v", ErrLine, i, err)
+		}
+	}
+}
+
+func TestYAMLBlock(t *testing.T) {
+	data := []struct {
+		body, want string
+	}{
+		{"", ` ""`},
+		{" \n ", ` " \n "`},
+		{"a\r\nb", ` "a\r\nb"`},
+		{"a", " |-\n  a"},
+		{"a\n", " |\n  a"},
+		{"a\n\n", " |+\n  a\n"},
+		{"a\n\nb", " |-\n  a\n\n  b"},
+		{" a", " |2-\n   a"},
+		{"\na", " |2-\n\n  a"},
+	}
+	for _, d := range data {
+		if has := yamlBlock(d.body, 0, 2); has != d.want {
+			t.Errorf("want: %q; has: %q", d.want, has)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	data := []struct {
+		s, want string
+		n       int
+		fails   bool
+	}{
+		{s: `n`, want: "\n", n: 1},
+		{s: `x41`, want: "A", n: 3},
+		{s: `U0001F422 `, want: "🐢", n: 9},
+		{s: `ud83d\ude00"`, want: "😀", n: 11},
+		{s: `ud83d\u00a"`, fails: true},
+		{s: `ud83d\u`, fails: true},
+		{s: `ud83d"`, fails: true},
+		{s: `ud83d\u0041"`, fails: true},
+	}
+	for _, d := range data {
+		var b strings.Builder
+		n, err := unescape(d.s, &b)
+		if d.fails {
+			if err == nil {
+				t.Errorf("want an error for %q; has: %q", d.s, b.String())
+			}
+			continue
+		}
+		if err != nil || n != d.n || b.String() != d.want {
+			t.Errorf("%q: want: %q (%d); has: %q (%d, %v)", d.s, d.want, d.n, b.String(), n, err)
+		}
+	}
+}
+
+// Bodies kept with |+ must not gain line breaks when they come last in the
+// file.
+func TestYAMLRoundTripTrailingNewlines(t *testing.T) {
+	f, err := NewFormat("yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"a\n\n", "a\n\n\n", "\n\n", "a\n"} {
+		want := []snippets.Snippet{{Name: "a", Body: "first\n\n"}, {Name: "b", Body: body}}
+		var b strings.Builder
+		if err := f.Write(&b, want); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			c, err := f.Load(strings.NewReader(b.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			has, _ := c.ListObj()
+			if !reflect.DeepEqual(has, want) {
+				t.Errorf("%q: want: %q; has: %q", body, want, has)
+			}
+			b.Reset()
+			f.Write(&b, has)
+		}
+	}
+}
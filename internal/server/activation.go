@@ -0,0 +1,51 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor passed by the service manager.
+const listenFDsStart = 3
+
+// Activated returns the listeners passed by systemd socket activation with
+// the LISTEN_FDS and LISTEN_PID variables. It returns none if the process was
+// not socket-activated. The variables are unset so that they are not
+// inherited by child processes.
+func Activated() ([]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+	return activated(os.Getenv, listenFDsStart)
+}
+
+// activated turns the file descriptors passed from start onwards into
+// listeners when the variables returned by getenv address this process.
+func activated(getenv func(string) string, start int) ([]net.Listener, error) {
+	pid, err := strconv.Atoi(getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("malformed LISTEN_FDS: %q", getenv("LISTEN_FDS"))
+	}
+	var result []net.Listener
+	for fd := start; fd < start+n; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		// NOTE: FileListener duplicates the descriptor, so the passed one
+		// is closed right away
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range result {
+				l.Close()
+			}
+			return nil, fmt.Errorf("file descriptor %d is not a listening socket: %w", fd, err)
+		}
+		result = append(result, l)
+	}
+	return result, nil
+}
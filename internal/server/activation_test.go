@@ -0,0 +1,91 @@
+//go:build unix
+
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// dup duplicates the descriptor of the file so that it can be passed on
+// without being closed twice.
+func dup(t *testing.T, f *os.File) int {
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestActivated(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "gsnip.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.UnixListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := dup(t, f)
+	f.Close()
+	env := map[string]string{
+		"LISTEN_PID": strconv.Itoa(os.Getpid()),
+		"LISTEN_FDS": "1",
+	}
+	ls, err := activated(func(k string) string { return env[k] }, fd)
+	if err != nil || len(ls) != 1 {
+		t.Fatalf("want one listener; has: %v (%v)", ls, err)
+	}
+	defer ls[0].Close()
+	go func() {
+		if conn, err := ls[0].Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	if !Alive(sock) {
+		t.Error("passed listener should accept connections")
+	}
+}
+
+func TestActivatedSkip(t *testing.T) {
+	data := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"not activated", map[string]string{}},
+		{"other process", map[string]string{"LISTEN_PID": "1", "LISTEN_FDS": "1"}},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			ls, err := activated(func(k string) string { return d.env[k] }, listenFDsStart)
+			if ls != nil || err != nil {
+				t.Errorf("want no listeners; has: %v (%v)", ls, err)
+			}
+		})
+	}
+}
+
+func TestActivatedFail(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "regular")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	env := map[string]string{"LISTEN_PID": strconv.Itoa(os.Getpid())}
+	for _, n := range []string{"", "-1", "x"} {
+		env["LISTEN_FDS"] = n
+		if _, err := activated(func(k string) string { return env[k] }, listenFDsStart); err == nil {
+			t.Errorf("malformed LISTEN_FDS %q should fail", n)
+		}
+	}
+	env["LISTEN_FDS"] = "1"
+	if _, err := activated(func(k string) string { return env[k] }, dup(t, f)); err == nil {
+		t.Error("a regular file should not be accepted as a listener")
+	}
+}
@@ -0,0 +1,39 @@
+//go:build linux
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkPeer rejects connections from processes run by other users, and
+// returns the ID of the connecting process. Both are read from the
+// SO_PEERCRED credentials of the socket.
+func checkPeer(conn net.Conn) (int, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, nil
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var cred *syscall.Ucred
+	var cerr error
+	err = raw.Control(func(fd uintptr) {
+		cred, cerr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if cerr != nil {
+		return 0, cerr
+	}
+	if uid := os.Getuid(); int(cred.Uid) != uid {
+		return 0, fmt.Errorf("connection from uid %d (pid %d)", cred.Uid, cred.Pid)
+	}
+	return int(cred.Pid), nil
+}
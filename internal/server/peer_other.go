@@ -0,0 +1,12 @@
+//go:build !linux
+
+package server
+
+import "net"
+
+// checkPeer accepts every connection on platforms without SO_PEERCRED, where
+// only the permissions of the socket keep other users out. The ID of the
+// connecting process is unknown.
+func checkPeer(conn net.Conn) (int, error) {
+	return 0, nil
+}
@@ -1,31 +1,41 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net"
+	"net/http"
 	"net/rpc"
 	"net/rpc/jsonrpc"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/mdm-code/gsnip/internal/config"
 	"github.com/mdm-code/gsnip/internal/fs"
 	"github.com/mdm-code/gsnip/internal/manager"
+	"github.com/mdm-code/gsnip/internal/metrics"
 	"github.com/mdm-code/gsnip/internal/stream"
+	"github.com/mdm-code/gsnip/internal/version"
 )
 
-type logger interface {
-	log(string, interface{})
-}
-
-type loggerAdapter func(string, interface{})
-
 // Server specifies the functional server interface.
 type Server interface {
 	Listen() error
+	Inherit(net.Listener)
+	SetIdleTimeout(time.Duration)
 	ShutDown()
 	AwaitSignal(...os.Signal)
 	AwaitConn()
-	Log(string, interface{})
+	Stop()
+	SetLogger(*slog.Logger)
+	ServeMetrics(string) error
 }
 
 // unixServer represents a server connecting over a Unix Domain Socket.
@@ -34,28 +44,154 @@ type unixServer struct {
 	listener    net.Listener
 	manager     *manager.Manager
 	signals     chan os.Signal
-	logger      logger
+	log         *slog.Logger
+	metrics     *metrics.Metrics
+	web         net.Listener
 	fileHandler *fs.FileHandler
+	done        chan struct{}
+	stopOnce    sync.Once
+	conns       sync.WaitGroup
+	idle        time.Duration
+	active      atomic.Int64
+	last        atomic.Int64
+	started     time.Time
+}
+
+// drainTimeout bounds the time a stopping server waits for connections in
+// progress to be served.
+const drainTimeout = 5 * time.Second
+
+// service exposes the manager over RPC to a single connection made by the
+// client process pid. Failed operations are reported in the reply rather than
+// as an RPC error so that the client receives their code.
+type service struct {
+	srv *unixServer
+	pid int
 }
 
-func newLogger() logger {
-	// NOTE: Add new loggers here
-	switch {
+// Hello answers the client opening the connection with the protocol version
+// and capabilities of the server: the requests it answers itself and the
+// ones the manager supports with its options.
+func (s *service) Hello(hello stream.Hello, welcome *stream.Welcome) error {
+	s.srv.log.Debug("hello", "pid", s.pid, "protocol", hello.Protocol, "version", hello.Version)
+	welcome.Protocol = stream.Protocol
+	welcome.Version = version.String()
+	welcome.Capabilities = []string{
+		stream.Shutdown.String(),
+		stream.Stats.String(),
+		stream.Ping.String(),
+		stream.Version.String(),
+	}
+	welcome.Capabilities = append(welcome.Capabilities, s.srv.manager.Capabilities()...)
+	return nil
+}
+
+// Execute runs the request against the snippet manager. Shutdown, Stats, Ping
+// and Version requests are handled by the server itself.
+func (s *service) Execute(request stream.Request, reply *stream.Reply) error {
+	start := time.Now()
+	reply.Protocol = stream.Protocol
+	switch request.Operation {
+	case stream.Shutdown:
+		reply.Result = stream.Success
+		reply.Body = []byte("shutting down")
+		reply.Code = stream.OK
+		defer s.srv.Stop()
+	case stream.Stats:
+		var b bytes.Buffer
+		s.srv.metrics.WriteTo(&b)
+		reply.Result = stream.Success
+		reply.Body = b.Bytes()
+		reply.Code = stream.OK
+	case stream.Ping:
+		reply.Result = stream.Success
+		reply.Body = []byte("pong")
+		reply.Code = stream.OK
+	case stream.Version:
+		body, err := json.Marshal(s.srv.status())
+		if err != nil {
+			reply.Result = stream.Failure
+			reply.Body = []byte(err.Error())
+			reply.Code = stream.Unknown
+			break
+		}
+		reply.Result = stream.Success
+		reply.Body = body
+		reply.Code = stream.OK
 	default:
-		return loggerAdapter(toStderr)
+		s.srv.manager.Execute(request, reply)
 	}
+	s.srv.report(request, reply, time.Since(start), s.pid)
+	return nil
 }
 
-func toStderr(level string, msg interface{}) {
-	fmt.Fprintf(os.Stderr, "%s %s: %s\n", "gsnipd", level, msg)
+// report logs the outcome of the request and records it in the metrics.
+// Failures caused by the request are logged as warnings, and the ones that
+// could not be classified as errors.
+func (s *unixServer) report(rq stream.Request, rp *stream.Reply, d time.Duration, pid int) {
+	s.metrics.Request(rq.Operation.String(), rp.Result == stream.Failure, rp.Code.String(), d)
+	level := slog.LevelInfo
+	args := []any{
+		"op", rq.Operation.String(),
+		"latency", d,
+		"result", rp.Code.String(),
+		"pid", pid,
+		"user", rq.User,
+	}
+	if names := subject(rq); names != "" {
+		args = append(args, "snippet", names)
+	}
+	if rp.Result == stream.Failure {
+		level = slog.LevelWarn
+		if rp.Code == stream.Unknown {
+			level = slog.LevelError
+		}
+		args = append(args, "error", string(rp.Body))
+	}
+	s.log.Log(context.Background(), level, "request", args...)
+}
+
+// status describes the running server.
+func (s *unixServer) status() stream.Status {
+	reloaded, err := s.manager.Reloaded()
+	var reloadErr string
+	if err != nil {
+		reloadErr = err.Error()
+	}
+	return stream.Status{
+		Version:     version.String(),
+		Protocol:    stream.Protocol,
+		Uptime:      time.Since(s.started),
+		Files:       []string{s.fileHandler.Name()},
+		Snippets:    s.manager.Count(),
+		Reloaded:    reloaded,
+		ReloadError: reloadErr,
+	}
+}
+
+// subject names the snippets the request refers to, separated with commas.
+func subject(rq stream.Request) string {
+	var names []string
+	switch rq.Operation {
+	case stream.Find, stream.Delete, stream.Rename, stream.Log, stream.Show:
+		names = strings.Fields(string(rq.Body))
+	case stream.Insert, stream.Update:
+		for _, l := range strings.Split(string(rq.Body), "\n") {
+			if f := strings.Fields(l); len(f) > 1 && f[0] == "startsnip" {
+				names = append(names, f[1])
+			}
+		}
+	}
+	return strings.Join(names, ",")
 }
 
 // NewServer creates a server connecting over the specified network. The address
-// of the sever could be a file or an address with a port.
-func NewServer(ntwrk string, addr string, fname string) (Server, error) {
+// of the sever could be a file or an address with a port. The snippet source
+// file fname is managed with the given options.
+func NewServer(ntwrk string, addr string, fname string, o manager.Options) (Server, error) {
 	switch ntwrk {
 	case "unix":
-		srv, err := newUnixServer(addr, fname)
+		srv, err := newUnixServer(addr, fname, o)
 		if err != nil {
 			return nil, err
 		}
@@ -65,39 +201,41 @@ func NewServer(ntwrk string, addr string, fname string) (Server, error) {
 	}
 }
 
-func newUnixServer(sock string, fname string) (*unixServer, error) {
+func newUnixServer(sock string, fname string, o manager.Options) (*unixServer, error) {
 	fh, err := fs.NewFileHandler(fname, fs.Perm)
 	if err != nil {
 		return nil, err
 	}
-	m, err := manager.NewManager(fh)
+	if o.Metrics == nil {
+		o.Metrics = metrics.New()
+	}
+	m, err := manager.NewManager(fh, o)
 	if err != nil {
 		return nil, err
 	}
+	o.Metrics.Snippets = m.Count
 	return &unixServer{
 		socket:      sock,
 		manager:     m,
 		signals:     make(chan os.Signal, 1),
-		logger:      newLogger(),
+		done:        make(chan struct{}),
+		log:         slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		metrics:     o.Metrics,
 		fileHandler: fh,
+		started:     time.Now(),
 	}, nil
 }
 
-func (l loggerAdapter) log(level string, msg interface{}) {
-	l(level, msg)
-}
-
-// Listen causes the server to start listening on the socket.
+// Listen causes the server to start listening on the socket, unless it was
+// given a listener with Inherit.
 func (s *unixServer) Listen() (err error) {
-	s.listener, err = net.Listen("unix", s.socket)
-	if err != nil {
-		return err
+	if s.listener == nil {
+		s.listener, err = listen(s.socket)
 	}
-	err = rpc.Register(s.manager)
 	if err != nil {
 		return err
 	}
-	s.Log("INFO", "listening on "+s.socket)
+	s.log.Info("listening", "sock", s.listener.Addr().String())
 	return
 }
 
@@ -120,34 +258,185 @@ func (s *unixServer) AwaitSignal(sig ...os.Signal) {
 			case <-s.signals:
 				rq := stream.Request{Operation: stream.Reload, Body: []byte{}}
 				var rp stream.Reply
-				err := s.manager.Execute(rq, &rp)
-				if err != nil {
-					s.Log("ERROR", err)
-					continue
-				}
-				s.Log("INFO", "reloaded snippet source file")
+				start := time.Now()
+				s.manager.Execute(rq, &rp)
+				s.report(rq, &rp, time.Since(start), os.Getpid())
 			}
 		}
 	}()
 }
 
-// AwaitConn waits for incoming connections. This is a blocking function.
+// Inherit makes the server accept connections on a listener created by
+// someone else, e.g. passed by systemd socket activation.
+func (s *unixServer) Inherit(l net.Listener) {
+	s.listener = l
+}
+
+// SetIdleTimeout makes the server stop once no connection was made for the
+// duration d. Zero keeps the server running.
+func (s *unixServer) SetIdleTimeout(d time.Duration) {
+	s.idle = d
+}
+
+// AwaitConn waits for incoming connections. This is a blocking function that
+// returns once the server is stopped and the connections in progress are
+// served.
 func (s *unixServer) AwaitConn() {
+	if s.idle > 0 {
+		go s.watchIdle()
+	}
 	for {
 		conn, err := s.listener.Accept()
 		if err != nil {
-			s.Log("INFO", err)
+			select {
+			case <-s.done:
+				s.drain()
+				return
+			default:
+			}
+			s.log.Warn("accept failed", "error", err)
+			continue
+		}
+		pid, err := checkPeer(conn)
+		if err != nil {
+			s.log.Error("rejected connection", "error", err)
+			conn.Close()
 			continue
 		}
-		s.Log(
-			"INFO",
-			fmt.Sprintf("received connection from %v", conn.RemoteAddr().Network()),
-		)
-		go jsonrpc.ServeConn(conn)
+		s.log.Debug("received connection", "pid", pid)
+		s.conns.Add(1)
+		s.touch(1)
+		go func() {
+			defer s.conns.Done()
+			defer s.touch(-1)
+			s.serve(conn, pid)
+		}()
+	}
+}
+
+// serve answers the requests made over the connection by the client process
+// pid.
+func (s *unixServer) serve(conn net.Conn, pid int) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Manager", &service{s, pid}); err != nil {
+		s.log.Error("could not serve connection", "error", err)
+		conn.Close()
+		return
 	}
+	srv.ServeCodec(jsonrpc.NewServerCodec(conn))
 }
 
-// Log logs the message with a provided severity level.
-func (s *unixServer) Log(level string, msg interface{}) {
-	s.logger.log(level, msg)
+// ServeMetrics serves the metrics in the Prometheus text format at /metrics
+// over HTTP on the TCP address addr, e.g. 127.0.0.1:9464. Only loopback
+// addresses are accepted, so the metrics are never exposed to the network.
+func (s *unixServer) ServeMetrics(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("metrics address is not a loopback address: %s", addr)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.web = l
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.metrics)
+	go http.Serve(l, mux)
+	s.log.Info("serving metrics", "addr", "http://"+l.Addr().String()+"/metrics")
+	return nil
+}
+
+// Stop stops accepting connections, which makes AwaitConn return.
+func (s *unixServer) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+		s.listener.Close()
+		if s.web != nil {
+			s.web.Close()
+		}
+	})
+}
+
+// touch records the start (1) or the end (-1) of a connection.
+func (s *unixServer) touch(delta int64) {
+	s.active.Add(delta)
+	s.last.Store(time.Now().UnixNano())
+}
+
+// watchIdle stops the server once it has been idle for the idle timeout.
+func (s *unixServer) watchIdle() {
+	s.last.Store(time.Now().UnixNano())
+	for {
+		since := time.Since(time.Unix(0, s.last.Load()))
+		if s.active.Load() == 0 && since >= s.idle {
+			s.log.Info("idle, stopping", "idle", s.idle)
+			s.Stop()
+			return
+		}
+		wait := s.idle - since
+		if wait <= 0 {
+			wait = s.idle
+		}
+		select {
+		case <-s.done:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// drain waits for the connections in progress to be served.
+func (s *unixServer) drain() {
+	served := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(served)
+	}()
+	select {
+	case <-served:
+	case <-time.After(drainTimeout):
+		s.log.Error("gave up waiting for connections in progress")
+	}
+}
+
+// SetLogger makes the server log to the logger.
+func (s *unixServer) SetLogger(l *slog.Logger) {
+	s.log = l
+}
+
+// ExecuteFile runs the request in-process against the snippet source file
+// fname managed as set in the configuration, answering it the way a server
+// would. The file has to exist. Shutdown, Stats, Ping and Version requests
+// need a running server and are rejected as unsupported.
+func ExecuteFile(c config.Config, fname string, request stream.Request, reply *stream.Reply) error {
+	reply.Protocol = stream.Protocol
+	switch request.Operation {
+	case stream.Shutdown, stream.Stats, stream.Ping, stream.Version:
+		err := fmt.Errorf("%w: %v needs a running server", manager.ErrUnsupported, request.Operation)
+		reply.Result = stream.Failure
+		reply.Body = []byte(err.Error())
+		reply.Code = stream.Unsupported
+		return err
+	}
+	// NOTE: A mistyped path must not leave an empty source file behind
+	if _, err := os.Stat(fname); err != nil {
+		return err
+	}
+	o, err := manager.Configure(c, fname)
+	if err != nil {
+		return err
+	}
+	fh, err := fs.NewFileHandler(fname, fs.Perm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	m, err := manager.NewManager(fh, o)
+	if err != nil {
+		return err
+	}
+	return m.Execute(request, reply)
 }
@@ -0,0 +1,387 @@
+//go:build unix
+
+package server
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mdm-code/gsnip/internal/config"
+	"github.com/mdm-code/gsnip/internal/manager"
+	"github.com/mdm-code/gsnip/internal/stream"
+)
+
+// serve starts a server on a socket in a temporary directory and returns the
+// socket along with a channel closed once AwaitConn returns. With inherit set,
+// the server is given a listener the way systemd passes it.
+func serve(t *testing.T, inherit bool, idle time.Duration) (*unixServer, string, chan struct{}) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "snippets")
+	os.WriteFile(fname, []byte("startsnip a \"\"\nbody\nendsnip\n"), 0600)
+	sock := filepath.Join(dir, "gsnip.sock")
+	s, err := newUnixServer(sock, fname, manager.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if inherit {
+		l, err := listen(sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Inherit(l)
+	}
+	s.SetIdleTimeout(idle)
+	if err := s.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.ShutDown)
+	done := make(chan struct{})
+	go func() {
+		s.AwaitConn()
+		close(done)
+	}()
+	return s, sock, done
+}
+
+// returned reports whether the channel is closed within the timeout.
+func returned(done chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	sock := filepath.Join(dir, "gsnip.sock")
+	if err := Prepare(sock); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0700 {
+		t.Fatalf("the directory should be created with 0700: %v (%v)", fi.Mode(), err)
+	}
+
+	l, err := listen(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(sock); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("the socket should be created with 0600: %v (%v)", fi.Mode(), err)
+	}
+	if err := Prepare(sock); !errors.Is(err, ErrRunning) {
+		t.Errorf("want: %v; has: %v", ErrRunning, err)
+	}
+
+	// NOTE: Closing a listener removes its socket, so the stale socket is
+	// left behind by a listener that is not closed
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if err := Prepare(sock); err != nil {
+		t.Fatalf("a stale socket should be removed: %v", err)
+	}
+	if _, err := os.Lstat(sock); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a stale socket should be removed: %v", err)
+	}
+
+	os.WriteFile(sock, []byte("data"), 0600)
+	if err := Prepare(sock); err == nil {
+		t.Error("a regular file should not be removed")
+	}
+	if _, err := os.Stat(sock); err != nil {
+		t.Errorf("a regular file should not be removed: %v", err)
+	}
+}
+
+func TestPrepareUnsafeDir(t *testing.T) {
+	base := t.TempDir()
+	shared := filepath.Join(base, "shared")
+	os.Mkdir(shared, 0700)
+	os.Chmod(shared, 0777)
+	link := filepath.Join(base, "link")
+	os.Mkdir(filepath.Join(base, "real"), 0700)
+	os.Symlink(filepath.Join(base, "real"), link)
+	for _, dir := range []string{shared, link} {
+		if err := Prepare(filepath.Join(dir, "gsnip.sock")); !errors.Is(err, ErrUnsafe) {
+			t.Errorf("%s: want: %v; has: %v", dir, ErrUnsafe, err)
+		}
+	}
+	if os.Getuid() == 0 {
+		owned := filepath.Join(base, "owned")
+		os.Mkdir(owned, 0700)
+		os.Chown(owned, 1, 1)
+		if err := Prepare(filepath.Join(owned, "gsnip.sock")); !errors.Is(err, ErrUnsafe) {
+			t.Errorf("want: %v; has: %v", ErrUnsafe, err)
+		}
+	}
+}
+
+func TestCheckPeer(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "gsnip.sock")
+	l, err := listen(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pid, err := checkPeer(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS == "linux" && pid != os.Getpid() {
+		t.Errorf("want: %d; has: %d", os.Getpid(), pid)
+	}
+}
+
+func TestStop(t *testing.T) {
+	s, sock, done := serve(t, false, 0)
+	s.Stop()
+	s.Stop()
+	if !returned(done, time.Second) {
+		t.Fatal("AwaitConn should return once the server is stopped")
+	}
+	if _, err := net.Dial("unix", sock); err == nil {
+		t.Error("the server should not accept connections once stopped")
+	}
+}
+
+func TestShutdownDrainsConnections(t *testing.T) {
+	_, sock, done := serve(t, false, 0)
+	idle, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	client, err := jsonrpc.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rp stream.Reply
+	if err := client.Call("Manager.Execute", stream.Request{Operation: stream.Shutdown}, &rp); err != nil {
+		t.Fatal(err)
+	}
+	if rp.Result != stream.Success || string(rp.Body) != "shutting down" {
+		t.Errorf("unexpected reply: %s (%s)", rp.Body, rp.Code)
+	}
+	client.Close()
+	if returned(done, 100*time.Millisecond) {
+		t.Fatal("AwaitConn should wait for the connections in progress")
+	}
+	idle.Close()
+	if !returned(done, time.Second) {
+		t.Fatal("AwaitConn should return once the connections are served")
+	}
+}
+
+func TestDialStarts(t *testing.T) {
+	for _, stale := range []bool{false, true} {
+		sock := filepath.Join(t.TempDir(), "gsnip.sock")
+		if stale {
+			l, err := listen(sock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.(*net.UnixListener).SetUnlinkOnClose(false)
+			l.Close()
+		}
+		if _, err := Dial(sock, nil); err == nil {
+			t.Fatal("dialing without a server should fail")
+		}
+		var l net.Listener
+		conn, err := Dial(sock, func() (err error) {
+			os.Remove(sock)
+			l, err = listen(sock)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("stale socket %t: %v", stale, err)
+		}
+		conn.Close()
+		l.Close()
+	}
+}
+
+func TestDialStartFails(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "gsnip.sock")
+	failure := errors.New("could not start")
+	if _, err := Dial(sock, func() error { return failure }); err != failure {
+		t.Errorf("want: %v; has: %v", failure, err)
+	}
+}
+
+func TestAwaitStart(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "gsnip.sock")
+	exited := make(chan error, 1)
+	exited <- errors.New("exit status 2")
+	if err := AwaitStart(sock, exited, time.Minute); !errors.Is(err, ErrExited) {
+		t.Errorf("want: %v; has: %v", ErrExited, err)
+	}
+	if err := AwaitStart(sock, nil, 200*time.Millisecond); err == nil {
+		t.Error("AwaitStart should give up after the timeout")
+	}
+}
+
+func TestAwaitStartGrace(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "gsnip.sock")
+	// NOTE: The started server lost the race to a server started by another
+	// client, which accepts connections shortly after
+	exited := make(chan error, 1)
+	exited <- errors.New("exit status 2")
+	ready := make(chan net.Listener, 1)
+	time.AfterFunc(startGrace/2, func() {
+		l, err := listen(sock)
+		if err != nil {
+			t.Error(err)
+		}
+		ready <- l
+	})
+	if err := AwaitStart(sock, exited, time.Minute); err != nil {
+		t.Errorf("the server started by another client should be accepted: %v", err)
+	}
+	if l := <-ready; l != nil {
+		l.Close()
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	idle := 200 * time.Millisecond
+	_, sock, done := serve(t, true, idle)
+	if !returned(done, 5*idle) {
+		t.Fatal("AwaitConn should return once the server is idle")
+	}
+	if _, err := net.Dial("unix", sock); err == nil {
+		t.Error("the idle server should not accept connections")
+	}
+}
+
+func TestIdleTimeoutOpenConnection(t *testing.T) {
+	idle := 200 * time.Millisecond
+	_, sock, done := serve(t, true, idle)
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if returned(done, 3*idle) {
+		t.Fatal("an open connection should keep the server running")
+	}
+	conn.Close()
+	if !returned(done, 5*idle) {
+		t.Fatal("AwaitConn should return once the connection is closed")
+	}
+}
+
+func TestExecuteFileAnswersAsServer(t *testing.T) {
+	_, sock, _ := serve(t, false, 0)
+	client, err := jsonrpc.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	fname := filepath.Join(t.TempDir(), "snippets")
+	os.WriteFile(fname, []byte("startsnip a \"\"\nbody\nendsnip\n"), 0600)
+
+	requests := []stream.Request{
+		{Operation: stream.List},
+		{Operation: stream.Find, Body: []byte("a")},
+		{Operation: stream.Find, Body: []byte("b")},
+		{Operation: stream.Insert, Body: []byte("startsnip b \"\"\nother\nendsnip\n")},
+		{Operation: stream.Insert, Body: []byte("startsnip b \"\"\nother\nendsnip\n")},
+		{Operation: stream.Dump},
+		{Operation: stream.Delete, Body: []byte("a")},
+		{Operation: stream.Undo},
+		{Operation: stream.Opcode(255)},
+	}
+	for _, rq := range requests {
+		var live, direct stream.Reply
+		if err := client.Call("Manager.Execute", rq, &live); err != nil {
+			t.Fatal(err)
+		}
+		ExecuteFile(config.Config{}, fname, rq, &direct)
+		if live.Result != direct.Result ||
+			string(live.Body) != string(direct.Body) ||
+			live.Code != direct.Code ||
+			live.Rev != direct.Rev ||
+			live.Protocol != direct.Protocol {
+			t.Errorf("%v: server: %+v; in-process: %+v", rq.Operation, live, direct)
+		}
+	}
+}
+
+func TestExecuteFileServerRequests(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "snippets")
+	os.WriteFile(fname, []byte("startsnip a \"\"\nbody\nendsnip\n"), 0600)
+	for _, op := range []stream.Opcode{stream.Shutdown, stream.Stats, stream.Ping, stream.Version} {
+		var rp stream.Reply
+		err := ExecuteFile(config.Config{}, fname, stream.Request{Operation: op}, &rp)
+		if !errors.Is(err, manager.ErrUnsupported) || rp.Code != stream.Unsupported {
+			t.Errorf("%v: want: %v; has: %v (%s)", op, manager.ErrUnsupported, err, rp.Code)
+		}
+	}
+}
+
+func TestHelloCapabilities(t *testing.T) {
+	_, sock, _ := serve(t, false, 0)
+	client, err := jsonrpc.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	var w stream.Welcome
+	if err := client.Call("Manager.Hello", stream.Hello{Protocol: stream.Protocol}, &w); err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range []stream.Opcode{stream.Find, stream.Shutdown, stream.Ping, stream.Version} {
+		if !w.Supports(op) {
+			t.Errorf("%s should be supported: %v", op, w.Capabilities)
+		}
+	}
+	if w.Supports(stream.Undo) {
+		t.Errorf("undo should not be supported without history: %v", w.Capabilities)
+	}
+}
+
+func TestExecuteFileMissing(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "typo.snip")
+	var rp stream.Reply
+	err := ExecuteFile(config.Config{Stats: true}, fname, stream.Request{Operation: stream.Find, Body: []byte("a")}, &rp)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want: %v; has: %v", os.ErrNotExist, err)
+	}
+	for _, f := range []string{fname, fname + ".stats"} {
+		if _, err := os.Stat(f); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s should not be created: %v", f, err)
+		}
+	}
+}
+
+func TestServeMetricsLoopback(t *testing.T) {
+	s, _, _ := serve(t, false, 0)
+	for _, addr := range []string{":9464", "0.0.0.0:9464", "example.com:9464", "127.0.0.1"} {
+		if err := s.ServeMetrics(addr); err == nil {
+			t.Errorf("%s should be refused", addr)
+		}
+	}
+	if err := s.ServeMetrics("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	s.web.Close()
+}
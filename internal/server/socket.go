@@ -0,0 +1,113 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrRunning is raised when another server already listens on the
+	// socket.
+	ErrRunning = errors.New("server is already running")
+	// ErrUnsafe is raised when users other than the current one could
+	// replace the socket.
+	ErrUnsafe = errors.New("unsafe socket directory")
+	// ErrExited is raised when a started server exits before accepting
+	// connections.
+	ErrExited = errors.New("server exited")
+)
+
+// probeTimeout bounds the time spent probing an existing socket.
+const probeTimeout = time.Second
+
+// startGrace is how long the socket is still polled after a started server
+// exited.
+const startGrace = time.Second
+
+// Alive reports whether a server accepts connections on the socket.
+func Alive(sock string) bool {
+	conn, err := net.DialTimeout("unix", sock, probeTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Dial connects to the server on the socket. When no server listens on it
+// and start is not nil, start is called to start one and the socket is
+// dialed again.
+func Dial(sock string, start func() error) (net.Conn, error) {
+	conn, err := net.Dial("unix", sock)
+	if start != nil && (errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED)) {
+		if err := start(); err != nil {
+			return nil, err
+		}
+		conn, err = net.Dial("unix", sock)
+	}
+	return conn, err
+}
+
+// AwaitStart waits until a server accepts connections on the socket. The
+// exited channel receives the result of the started server process. A server
+// started at the same time by another client makes it exit, so the socket is
+// still polled for a moment after the exit before it is reported.
+func AwaitStart(sock string, exited <-chan error, timeout time.Duration) error {
+	var failure error
+	deadline := time.Now().Add(timeout)
+	for !Alive(sock) {
+		select {
+		case err := <-exited:
+			failure, exited = fmt.Errorf("%w (%v)", ErrExited, err), nil
+			deadline = time.Now().Add(startGrace)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			if failure != nil {
+				return failure
+			}
+			return fmt.Errorf("server did not start within %s", timeout)
+		}
+	}
+	return nil
+}
+
+// Prepare makes the socket path ready to listen on. It creates the socket
+// directory accessible to the owner only, refuses directories other users
+// could swap the socket in, and removes a stale socket left behind by a
+// server that is no longer running. Files other than sockets are never
+// removed.
+func Prepare(sock string) error {
+	if err := os.MkdirAll(filepath.Dir(sock), 0700); err != nil {
+		return err
+	}
+	if err := CheckDir(sock); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(sock)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("not a socket, refusing to remove: %s", sock)
+	}
+	if Alive(sock) {
+		return fmt.Errorf("%w: %s", ErrRunning, sock)
+	}
+	return os.Remove(sock)
+}
+
+// PIDPath returns the path of the PID file kept next to the socket: the
+// socket name with the .sock extension replaced by .pid.
+func PIDPath(sock string) string {
+	return strings.TrimSuffix(sock, ".sock") + ".pid"
+}
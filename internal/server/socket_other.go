@@ -0,0 +1,27 @@
+//go:build !unix
+
+package server
+
+import (
+	"net"
+	"os"
+)
+
+// listen creates the socket and restricts it to the owner.
+func listen(sock string) (net.Listener, error) {
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(sock, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// CheckDir does not verify the socket directory on systems without Unix file
+// ownership.
+func CheckDir(sock string) error {
+	return nil
+}
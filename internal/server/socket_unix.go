@@ -0,0 +1,41 @@
+//go:build unix
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// listen creates the socket readable and writable by the owner only. The
+// umask is narrowed while the socket file is created, so there is no window
+// in which other users could connect.
+func listen(sock string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", sock)
+}
+
+// CheckDir verifies that only the current user can replace the socket or the
+// PID file kept next to it: their directory must not be a symbolic link, must
+// be owned by the user and must not be writable by the group or others.
+func CheckDir(sock string) error {
+	dir := filepath.Dir(sock)
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%w: not a directory: %s", ErrUnsafe, dir)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%w: %s is owned by uid %d", ErrUnsafe, dir, st.Uid)
+	}
+	if perm := fi.Mode().Perm(); perm&0022 != 0 {
+		return fmt.Errorf("%w: %s is writable by other users (%s)", ErrUnsafe, dir, perm)
+	}
+	return nil
+}
@@ -1,14 +1,31 @@
 package snippets
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"unicode"
+)
+
+var (
+	// ErrNotFound is raised when the snippet is not stored in the container.
+	ErrNotFound = errors.New("snippet was not found")
+	// ErrExists is raised when a snippet with the same name is already stored.
+	ErrExists = errors.New("snippet already exists")
+	// ErrAliasTaken is raised when a name or an alias of the snippet is
+	// already used by another snippet.
+	ErrAliasTaken = errors.New("name or alias is already taken")
 )
 
 // Container provides an interface for a type handling snippet storage.
 type Container interface {
 	Insert(Snippet) error
+	Update(Snippet) error
 	Find(string) (Snippet, error)
 	List() ([]string, error)
 	Delete(string) error
@@ -17,29 +34,129 @@ type Container interface {
 
 // Snippet carries information about a single code snippet.
 type Snippet struct {
-	Name string
-	Desc string
-	Body string
+	Name  string            `json:"name"`
+	Desc  string            `json:"desc"`
+	Body  string            `json:"body"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// Aliases lists the alternate names declared in the aliases attribute. They
+// are separated with commas or white space.
+func (s Snippet) Aliases() []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, a := range strings.FieldsFunc(s.Attrs["aliases"], func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		if !seen[a] {
+			seen[a] = true
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// Rev returns the revision of the snippet: a short hash of its contents that
+// changes whenever its name, description, attributes or body change.
+func (s Snippet) Rev() string {
+	sum := sha256.Sum256([]byte(s.Repr()))
+	return hex.EncodeToString(sum[:6])
 }
 
 // Repr provides an in-file snippet text representation.
+//
+// Snippets whose body would otherwise end prematurely on a line starting with
+// `endsnip` are written with a heredoc-style delimiter instead.
 func (s Snippet) Repr() string {
-	return fmt.Sprintf("startsnip %s \"%s\"\n%s\nendsnip\n\n", s.Name, s.Desc, s.Body)
+	var b strings.Builder
+	fmt.Fprintf(&b, "startsnip %s %s", s.Name, quote(s.Desc))
+	keys := make([]string, 0, len(s.Attrs))
+	for k := range s.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, quoteAttr(s.Attrs[k]))
+	}
+	if delim := s.delim(); delim != "" {
+		fmt.Fprintf(&b, " <<%s\n%s\n%s\n\n", delim, s.Body, delim)
+	} else {
+		fmt.Fprintf(&b, "\n%s\nendsnip\n\n", s.Body)
+	}
+	return b.String()
 }
 
-// mapContainer is a map-based implementation of a snippet Container.
+var quoteReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\t", `\t`,
+)
+
+// quote wraps the text in double quotes escaping characters that would
+// otherwise break the signature line.
+func quote(s string) string {
+	return `"` + quoteReplacer.Replace(s) + `"`
+}
+
+// quoteAttr quotes attribute values unless they make up a bare word.
+func quoteAttr(s string) string {
+	if s == "" || strings.IndexFunc(s, unicode.IsSpace) >= 0 ||
+		strings.ContainsAny(s, `"\`) || strings.HasPrefix(s, "<<") {
+		return quote(s)
+	}
+	return s
+}
+
+// delim picks a heredoc delimiter that does not clash with any body line. It
+// returns an empty string when the body is unambiguous with `endsnip`.
+func (s Snippet) delim() string {
+	lines := make(map[string]bool)
+	ambiguous := false
+	for _, l := range strings.Split(s.Body, "\n") {
+		l = strings.TrimSpace(l)
+		lines[l] = true
+		if strings.HasPrefix(l, "endsnip") {
+			ambiguous = true
+		}
+	}
+	if !ambiguous {
+		return ""
+	}
+	delim := "END"
+	for i := 1; lines[delim]; i++ {
+		delim = "END" + strconv.Itoa(i)
+	}
+	return delim
+}
+
+// mapContainer is a map-based implementation of a snippet Container. Aliases
+// map alternate names onto the names of the snippets declaring them.
 type mapContainer struct {
-	cntr map[string]Snippet
+	cntr      map[string]Snippet
+	aliases   map[string]string
+	validator Validator
 	sync.RWMutex
 }
 
-// NewSnippetsContainer creates a fresh instance of snippets container.
+// NewSnippetsContainer creates a fresh instance of snippets container that
+// checks inserted snippets against the DefaultValidator.
 //
 // Allowed types (t): map
 func NewSnippetsContainer(t string) (Container, error) {
+	return NewValidatedContainer(t, DefaultValidator)
+}
+
+// NewValidatedContainer creates a fresh instance of snippets container that
+// checks inserted snippets against the validator v.
+//
+// Allowed types (t): map
+func NewValidatedContainer(t string, v Validator) (Container, error) {
 	switch t {
 	case "map":
-		return newMap(), nil
+		m := newMap()
+		m.validator = v
+		return m, nil
 	default:
 		return nil, fmt.Errorf("container type (%s) is not implemented", t)
 	}
@@ -49,31 +166,95 @@ func NewSnippetsContainer(t string) (Container, error) {
 // type.
 func newMap() *mapContainer {
 	return &mapContainer{
-		cntr: make(map[string]Snippet),
+		cntr:      make(map[string]Snippet),
+		aliases:   make(map[string]string),
+		validator: DefaultValidator,
 	}
 }
 
 // Insert inserts a snippet to the container.
 func (s *mapContainer) Insert(snip Snippet) (err error) {
+	if err := s.validator.Validate(snip); err != nil {
+		return err
+	}
 	s.Lock()
 	defer s.Unlock()
-	if _, exists := s.cntr[snip.Name]; !exists {
-		s.cntr[snip.Name] = snip
-		err = nil
-	} else {
-		err = fmt.Errorf("snippet %s already exists", snip.Name)
+	if _, exists := s.cntr[snip.Name]; exists {
+		return fmt.Errorf("%w: %s", ErrExists, snip.Name)
 	}
-	return
+	if err := s.claim(snip); err != nil {
+		return err
+	}
+	s.put(snip)
+	return nil
+}
+
+// Update replaces the snippet stored under the same name or inserts it if
+// there is none.
+func (s *mapContainer) Update(snip Snippet) error {
+	if err := s.validator.Validate(snip); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if err := s.claim(snip); err != nil {
+		return err
+	}
+	s.drop(snip.Name)
+	s.put(snip)
+	return nil
+}
+
+// claim checks that neither the name nor the aliases of the snippet are used
+// by another snippet.
+func (s *mapContainer) claim(snip Snippet) error {
+	if owner, ok := s.aliases[snip.Name]; ok && owner != snip.Name {
+		return fmt.Errorf("%w: %s is an alias of %s", ErrAliasTaken, snip.Name, owner)
+	}
+	for _, a := range snip.Aliases() {
+		if a == snip.Name {
+			return fmt.Errorf("%w: %s is an alias of itself", ErrAliasTaken, a)
+		}
+		if _, ok := s.cntr[a]; ok {
+			return fmt.Errorf("%w: %s is a snippet name", ErrAliasTaken, a)
+		}
+		if owner, ok := s.aliases[a]; ok && owner != snip.Name {
+			return fmt.Errorf("%w: %s is an alias of %s", ErrAliasTaken, a, owner)
+		}
+	}
+	return nil
+}
+
+// put stores the snippet and indexes its aliases.
+func (s *mapContainer) put(snip Snippet) {
+	s.cntr[snip.Name] = snip
+	for _, a := range snip.Aliases() {
+		s.aliases[a] = snip.Name
+	}
+}
+
+// drop removes the snippet along with its aliases.
+func (s *mapContainer) drop(name string) {
+	if old, ok := s.cntr[name]; ok {
+		for _, a := range old.Aliases() {
+			delete(s.aliases, a)
+		}
+	}
+	delete(s.cntr, name)
 }
 
-// Find searches for a snippet name in the container.
+// Find searches for a snippet name in the container. Aliases resolve to the
+// snippets declaring them.
 func (s *mapContainer) Find(str string) (Snippet, error) {
 	s.RLock()
 	defer s.RUnlock()
 	var snip Snippet
 	snip, ok := s.cntr[str]
 	if !ok {
-		return snip, fmt.Errorf("snippet was not found")
+		if name, alias := s.aliases[str]; alias {
+			return s.cntr[name], nil
+		}
+		return snip, fmt.Errorf("%w: %s", ErrNotFound, str)
 	}
 	return snip, nil
 }
@@ -92,11 +273,11 @@ func (s *mapContainer) List() ([]string, error) {
 	return result, nil
 }
 
-// Delete deletes a snippet from the container.
+// Delete deletes a snippet from the container. Aliases are not resolved.
 func (s *mapContainer) Delete(key string) error {
 	s.Lock()
 	defer s.Unlock()
-	delete(s.cntr, key)
+	s.drop(key)
 	return nil
 }
 
@@ -1,19 +1,40 @@
 package snippets
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
 )
 
 func TestSnippetRepr(t *testing.T) {
-	s := Snippet{"func", "a function", "def func(): return None"}
+	s := Snippet{Name: "func", Desc: "a function", Body: "def func(): return None"}
 	want := fmt.Sprintf("startsnip %s \"%s\"\n%s\nendsnip\n\n", s.Name, s.Desc, s.Body)
 	if want != s.Repr() {
 		t.Errorf("want: %s; has %s", want, s.Repr())
 	}
 }
 
+func TestSnippetReprHeredoc(t *testing.T) {
+	data := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"plain", "endsnipping", "startsnip doc \"d\" <<END\nendsnipping\nEND\n\n"},
+		{"clash", "endsnip\nEND", "startsnip doc \"d\" <<END1\nendsnip\nEND\nEND1\n\n"},
+		{"none", "body", "startsnip doc \"d\"\nbody\nendsnip\n\n"},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			s := Snippet{Name: "doc", Desc: "d", Body: d.body}
+			if has := s.Repr(); has != d.want {
+				t.Errorf("want: %q; has %q", d.want, has)
+			}
+		})
+	}
+}
+
 func TestContainerFindMethod(t *testing.T) {
 	ss := newMap()
 	ss.Insert(Snippet{
@@ -29,15 +50,32 @@ func TestContainerFindMethod(t *testing.T) {
 
 func TestSnippetsMapInsert(t *testing.T) {
 	ss := newMap()
-	err := ss.Insert(Snippet{"name", "desc", "body"})
+	err := ss.Insert(Snippet{Name: "name", Desc: "desc", Body: "body"})
 	if err != nil {
 		t.Error("Insert() fails to insert Snippet to map")
 	}
 }
 
+func TestSnippetsMapUpdate(t *testing.T) {
+	ss := newMap()
+	ss.Insert(Snippet{Name: "name", Desc: "desc", Body: "body"})
+	data := []Snippet{
+		{Name: "name", Desc: "replaced", Body: "new body"},
+		{Name: "other", Desc: "inserted", Body: "body"},
+	}
+	for _, d := range data {
+		if err := ss.Update(d); err != nil {
+			t.Errorf("Update() fails to store %v: %s", d, err)
+		}
+		if has, _ := ss.Find(d.Name); !reflect.DeepEqual(has, d) {
+			t.Errorf("want: %v; has: %v", d, has)
+		}
+	}
+}
+
 func TestSnippetsMapFind(t *testing.T) {
 	ss := newMap()
-	ss.cntr["func"] = Snippet{"func", "Go function", "func ${1:name} () {}"}
+	ss.cntr["func"] = Snippet{Name: "func", Desc: "Go function", Body: "func ${1:name} () {}"}
 	_, err := ss.Find("func")
 	if err != nil {
 		t.Error("existing snippet signature could not be retrieved")
@@ -47,9 +85,9 @@ func TestSnippetsMapFind(t *testing.T) {
 func TestSnippetsMapList(t *testing.T) {
 	ss := newMap()
 	ss.cntr = map[string]Snippet{
-		"func":   {"func", "Go function", "func() {}"},
-		"struct": {"struct", "Go struct", "type struct {}"},
-		"map":    {"map", "Go map", "map[string]string"},
+		"func":   {Name: "func", Desc: "Go function", Body: "func() {}"},
+		"struct": {Name: "struct", Desc: "Go struct", Body: "type struct {}"},
+		"map":    {Name: "map", Desc: "Go map", Body: "map[string]string"},
 	}
 	want := []string{"func\tGo function", "map\tGo map", "struct\tGo struct"}
 	if has, err := ss.List(); !reflect.DeepEqual(has, want) || err != nil {
@@ -60,14 +98,14 @@ func TestSnippetsMapList(t *testing.T) {
 func TestSnippetsListObj(t *testing.T) {
 	ss := newMap()
 	ss.cntr = map[string]Snippet{
-		"func":   {"func", "Go function", "func() {}"},
-		"struct": {"struct", "Go struct", "type struct {}"},
-		"map":    {"map", "Go map", "map[string]string"},
+		"func":   {Name: "func", Desc: "Go function", Body: "func() {}"},
+		"struct": {Name: "struct", Desc: "Go struct", Body: "type struct {}"},
+		"map":    {Name: "map", Desc: "Go map", Body: "map[string]string"},
 	}
 	want := []Snippet{
-		{"func", "Go function", "func() {}"},
-		{"map", "Go map", "map[string]string"},
-		{"struct", "Go struct", "type struct {}"},
+		{Name: "func", Desc: "Go function", Body: "func() {}"},
+		{Name: "map", Desc: "Go map", Body: "map[string]string"},
+		{Name: "struct", Desc: "Go struct", Body: "type struct {}"},
 	}
 	objects, _ := ss.ListObj()
 	for i, o := range objects {
@@ -80,9 +118,9 @@ func TestSnippetsListObj(t *testing.T) {
 func TestSnippetsMapDelete(t *testing.T) {
 	sm := newMap()
 	sm.cntr = map[string]Snippet{
-		"func":   {"func", "Go function", "func() {}"},
-		"struct": {"struct", "Go struct", "type struct {}"},
-		"map":    {"map", "Go map", "map[string]string"},
+		"func":   {Name: "func", Desc: "Go function", Body: "func() {}"},
+		"struct": {Name: "struct", Desc: "Go struct", Body: "type struct {}"},
+		"map":    {Name: "map", Desc: "Go map", Body: "map[string]string"},
 	}
 	toDel := "map"
 	sm.Delete(toDel)
@@ -90,3 +128,67 @@ func TestSnippetsMapDelete(t *testing.T) {
 		t.Errorf("snippet `%s` is still in map", toDel)
 	}
 }
+
+func TestSnippetRev(t *testing.T) {
+	s := Snippet{Name: "for", Desc: "loop", Body: "for {}", Attrs: map[string]string{"lang": "go", "tags": "x"}}
+	same := Snippet{Name: "for", Desc: "loop", Body: "for {}", Attrs: map[string]string{"tags": "x", "lang": "go"}}
+	if s.Rev() != same.Rev() || len(s.Rev()) != 12 {
+		t.Errorf("revisions should match: %s, %s", s.Rev(), same.Rev())
+	}
+	for _, other := range []Snippet{
+		{Name: "for2", Desc: "loop", Body: "for {}", Attrs: s.Attrs},
+		{Name: "for", Desc: "loops", Body: "for {}", Attrs: s.Attrs},
+		{Name: "for", Desc: "loop", Body: "for {} ", Attrs: s.Attrs},
+		{Name: "for", Desc: "loop", Body: "for {}"},
+	} {
+		if other.Rev() == s.Rev() {
+			t.Errorf("revision should change for %v", other)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	c, _ := NewSnippetsContainer("map")
+	iferr := Snippet{Name: "iferr", Body: "if err != nil {}", Attrs: map[string]string{"aliases": "errchk, ife,errchk"}}
+	if has := iferr.Aliases(); !reflect.DeepEqual(has, []string{"errchk", "ife"}) {
+		t.Errorf("has: %v", has)
+	}
+	if err := c.Insert(iferr); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := c.Find("errchk"); err != nil || s.Name != "iferr" {
+		t.Errorf("alias should resolve to iferr; has: %v (%v)", s, err)
+	}
+
+	data := []struct {
+		name string
+		s    Snippet
+	}{
+		{"name taken by alias", Snippet{Name: "ife", Body: "x"}},
+		{"alias taken by name", Snippet{Name: "other", Body: "x", Attrs: map[string]string{"aliases": "iferr"}}},
+		{"alias taken by alias", Snippet{Name: "other", Body: "x", Attrs: map[string]string{"aliases": "errchk"}}},
+		{"alias of itself", Snippet{Name: "other", Body: "x", Attrs: map[string]string{"aliases": "other"}}},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			if err := c.Insert(d.s); !errors.Is(err, ErrAliasTaken) {
+				t.Errorf("want: %v; has: %v", ErrAliasTaken, err)
+			}
+			if err := c.Update(d.s); !errors.Is(err, ErrAliasTaken) {
+				t.Errorf("want: %v; has: %v", ErrAliasTaken, err)
+			}
+		})
+	}
+
+	iferr.Attrs = map[string]string{"aliases": "ife"}
+	if err := c.Update(iferr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Find("errchk"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("dropped alias should not resolve; has: %v", err)
+	}
+	c.Delete("iferr")
+	if _, err := c.Find("ife"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("alias of a deleted snippet should not resolve; has: %v", err)
+	}
+}
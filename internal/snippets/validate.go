@@ -0,0 +1,109 @@
+package snippets
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	// ErrInvalid is matched by every error raised by a Validator.
+	ErrInvalid = errors.New("invalid snippet")
+	// ErrEmptyName is raised when the snippet has no name.
+	ErrEmptyName = errors.New("is empty")
+	// ErrBadName is raised when the name does not match the name pattern.
+	ErrBadName = errors.New("does not match the allowed pattern")
+	// ErrReservedName is raised when the name is a reserved word.
+	ErrReservedName = errors.New("is a reserved word")
+	// ErrTooLong is raised when a snippet field exceeds its maximum length.
+	ErrTooLong = errors.New("is too long")
+)
+
+// DefaultValidator holds the naming rules described in the snippet syntax:
+// names must not contain white space or double quotes and must not collide
+// with reserved gsnip commands.
+var DefaultValidator = Validator{
+	Pattern:    regexp.MustCompile(`^[^\s"]+$`),
+	Reserved:   []string{"@LST"},
+	MaxNameLen: 128,
+	MaxDescLen: 512,
+	MaxBodyLen: 1 << 20,
+}
+
+// Validator checks snippets before they are stored in a container.
+//
+// A nil Pattern accepts any non-empty name, and a zero maximum length turns
+// the corresponding length check off.
+type Validator struct {
+	Pattern    *regexp.Regexp
+	Reserved   []string
+	MaxNameLen int
+	MaxDescLen int
+	MaxBodyLen int
+}
+
+// ValidationError describes the rule broken by the snippet.
+type ValidationError struct {
+	Name  string
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %q: %s %s", ErrInvalid, e.Name, e.Field, e.Err)
+}
+
+// Unwrap returns the broken rule.
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// Is makes every validation error match ErrInvalid.
+func (e *ValidationError) Is(target error) bool { return target == ErrInvalid }
+
+// Validate returns a *ValidationError when the snippet breaks any of the rules.
+func (v Validator) Validate(s Snippet) error {
+	fail := func(field string, err error) error {
+		return &ValidationError{Name: s.Name, Field: field, Err: err}
+	}
+	switch {
+	case s.Name == "":
+		return fail("name", ErrEmptyName)
+	case v.checkName(s.Name) != nil:
+		return fail("name", v.checkName(s.Name))
+	case tooLong(s.Desc, v.MaxDescLen):
+		return fail("description", ErrTooLong)
+	case tooLong(s.Body, v.MaxBodyLen):
+		return fail("body", ErrTooLong)
+	}
+	for _, a := range s.Aliases() {
+		if err := v.checkName(a); err != nil {
+			return fail("alias "+a, err)
+		}
+	}
+	return nil
+}
+
+// checkName applies the naming rules to snippet names and aliases.
+func (v Validator) checkName(name string) error {
+	switch {
+	case tooLong(name, v.MaxNameLen):
+		return ErrTooLong
+	case v.Pattern != nil && !v.Pattern.MatchString(name):
+		return ErrBadName
+	case v.isReserved(name):
+		return ErrReservedName
+	}
+	return nil
+}
+
+func (v Validator) isReserved(name string) bool {
+	for _, r := range v.Reserved {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+func tooLong(s string, max int) bool {
+	return max > 0 && len(s) > max
+}
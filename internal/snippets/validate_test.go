@@ -0,0 +1,86 @@
+package snippets
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestValidatorPasses(t *testing.T) {
+	data := []Snippet{
+		{Name: "func", Desc: "Go function", Body: "func() {}"},
+		{Name: "if-err", Desc: "", Body: ""},
+		{Name: "@LSTX", Desc: "only exact reserved words are rejected", Body: ""},
+	}
+	for _, d := range data {
+		if err := DefaultValidator.Validate(d); err != nil {
+			t.Errorf("unexpected error for %v: %s", d, err)
+		}
+	}
+}
+
+func TestValidatorFails(t *testing.T) {
+	data := []struct {
+		name string
+		snip Snippet
+		want error
+	}{
+		{"empty", Snippet{Name: ""}, ErrEmptyName},
+		{"space", Snippet{Name: "two words"}, ErrBadName},
+		{"tab", Snippet{Name: "two\twords"}, ErrBadName},
+		{"quote", Snippet{Name: `"quoted"`}, ErrBadName},
+		{"reserved", Snippet{Name: "@LST"}, ErrReservedName},
+		{"long name", Snippet{Name: strings.Repeat("n", 129)}, ErrTooLong},
+		{"long desc", Snippet{Name: "n", Desc: strings.Repeat("d", 513)}, ErrTooLong},
+		{"long body", Snippet{Name: "n", Body: strings.Repeat("b", 1<<20+1)}, ErrTooLong},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			err := DefaultValidator.Validate(d.snip)
+			var verr *ValidationError
+			if !errors.Is(err, d.want) || !errors.Is(err, ErrInvalid) || !errors.As(err, &verr) {
+				t.Errorf("want: %v; has: %v", d.want, err)
+			}
+		})
+	}
+}
+
+func TestCustomValidator(t *testing.T) {
+	v := Validator{
+		Pattern:    regexp.MustCompile(`^[a-z]+$`),
+		Reserved:   []string{"list"},
+		MaxBodyLen: 4,
+	}
+	c, err := NewValidatedContainer("map", v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Insert(Snippet{Name: "func", Body: "body"}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	for _, s := range []Snippet{
+		{Name: "Func"},
+		{Name: "list"},
+		{Name: "body", Body: "too long"},
+	} {
+		if err := c.Insert(s); !errors.Is(err, ErrInvalid) {
+			t.Errorf("want: %v; has: %v", ErrInvalid, err)
+		}
+	}
+}
+
+func TestContainerInsertEmptyName(t *testing.T) {
+	c, _ := NewSnippetsContainer("map")
+	if err := c.Insert(Snippet{}); !errors.Is(err, ErrEmptyName) {
+		t.Errorf("want: %v; has: %v", ErrEmptyName, err)
+	}
+}
+
+func TestValidateAliases(t *testing.T) {
+	s := Snippet{Name: "ok", Body: "x", Attrs: map[string]string{"aliases": "fine,@LST"}}
+	err := DefaultValidator.Validate(s)
+	if !errors.Is(err, ErrReservedName) {
+		t.Errorf("want: %v; has: %v", ErrReservedName, err)
+	}
+}
@@ -0,0 +1,188 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage records how often and how recently a snippet was used. Added is when
+// the snippet was created, if that happened after usage started to be
+// recorded.
+type Usage struct {
+	Hits  int       `json:"hits"`
+	Last  time.Time `json:"last"`
+	Added time.Time `json:"added,omitempty"`
+}
+
+// Score ranks the usage by frecency: hits weighted by how recent the last use
+// was at the time now.
+func (u Usage) Score(now time.Time) int {
+	if u.Hits == 0 {
+		return 0
+	}
+	age := now.Sub(u.Last)
+	day := 24 * time.Hour
+	var weight int
+	switch {
+	case age < 4*day:
+		weight = 100
+	case age < 14*day:
+		weight = 70
+	case age < 31*day:
+		weight = 50
+	case age < 90*day:
+		weight = 30
+	default:
+		weight = 10
+	}
+	return u.Hits * weight
+}
+
+// Stats keeps usage of snippets in a file kept apart from the snippet source.
+// Usage of deleted snippets is kept so that it is back when the deletion is
+// undone.
+type Stats struct {
+	path  string
+	since time.Time
+	usage map[string]Usage
+	now   func() time.Time
+	sync.Mutex
+}
+
+// state is the on-disk representation of the statistics.
+type state struct {
+	Since time.Time        `json:"since"`
+	Usage map[string]Usage `json:"usage"`
+}
+
+// Open loads the statistics stored at path, creating the file if it does not
+// exist yet. An empty path keeps the statistics in memory only.
+func Open(path string) (*Stats, error) {
+	s := &Stats{path: path, usage: make(map[string]Usage), now: time.Now}
+	s.since = s.now().UTC()
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, s.save()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("malformed usage statistics %s: %w", path, err)
+	}
+	if !st.Since.IsZero() {
+		s.since = st.Since
+	}
+	if st.Usage != nil {
+		s.usage = st.Usage
+	}
+	return s, nil
+}
+
+// Since returns the time when the usage started to be recorded.
+func (s *Stats) Since() time.Time {
+	s.Lock()
+	defer s.Unlock()
+	return s.since
+}
+
+// Hit records the use of the snippet.
+func (s *Stats) Hit(name string) error {
+	s.Lock()
+	defer s.Unlock()
+	u := s.usage[name]
+	u.Hits++
+	u.Last = s.now().UTC()
+	s.usage[name] = u
+	return s.save()
+}
+
+// Add records that the snippet was created. Usage recorded under its name
+// before is kept.
+func (s *Stats) Add(name string) error {
+	s.Lock()
+	defer s.Unlock()
+	u := s.usage[name]
+	u.Added = s.now().UTC()
+	s.usage[name] = u
+	return s.save()
+}
+
+// Rename moves the usage of the snippet to its new name.
+func (s *Stats) Rename(old, new string) error {
+	s.Lock()
+	defer s.Unlock()
+	u, ok := s.usage[old]
+	if !ok {
+		return nil
+	}
+	delete(s.usage, old)
+	s.usage[new] = u
+	return s.save()
+}
+
+// Seen returns the time since which the snippet is known to exist: when it
+// was added or when usage started to be recorded, whichever is later.
+func (s *Stats) Seen(name string) time.Time {
+	s.Lock()
+	defer s.Unlock()
+	if added := s.usage[name].Added; added.After(s.since) {
+		return added
+	}
+	return s.since
+}
+
+// Get returns the usage of the snippet.
+func (s *Stats) Get(name string) Usage {
+	s.Lock()
+	defer s.Unlock()
+	return s.usage[name]
+}
+
+// Rank sorts names by frecency starting from the highest score. Names with
+// equal scores are sorted alphabetically.
+func (s *Stats) Rank(names []string) {
+	s.Lock()
+	defer s.Unlock()
+	now := s.now()
+	scores := make(map[string]int, len(names))
+	for _, n := range names {
+		scores[n] = s.usage[n].Score(now)
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		if scores[names[i]] != scores[names[j]] {
+			return scores[names[i]] > scores[names[j]]
+		}
+		return names[i] < names[j]
+	})
+}
+
+// Now returns the time used to score usage.
+func (s *Stats) Now() time.Time {
+	return s.now()
+}
+
+// save writes the statistics to a temporary file and moves it into place.
+func (s *Stats) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(state{s.since, s.usage})
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,124 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScore(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	data := []struct {
+		name string
+		u    Usage
+		want int
+	}{
+		{"unused", Usage{}, 0},
+		{"today", Usage{Hits: 2, Last: now.Add(-time.Hour)}, 200},
+		{"last week", Usage{Hits: 2, Last: now.Add(-7 * day)}, 140},
+		{"last month", Usage{Hits: 2, Last: now.Add(-20 * day)}, 100},
+		{"this quarter", Usage{Hits: 2, Last: now.Add(-60 * day)}, 60},
+		{"long ago", Usage{Hits: 2, Last: now.Add(-365 * day)}, 20},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			if has := d.u.Score(now); has != d.want {
+				t.Errorf("want: %d; has: %d", d.want, has)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	s, _ := Open("")
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	s.usage["old"] = Usage{Hits: 50, Last: now.Add(-200 * 24 * time.Hour)}
+	s.Hit("fresh")
+	s.Hit("fresh")
+	s.Hit("once")
+	names := []string{"unused", "once", "old", "fresh", "also-unused"}
+	s.Rank(names)
+	want := []string{"old", "fresh", "once", "also-unused", "unused"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("want: %v; has: %v", want, names)
+	}
+}
+
+func TestPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snippets.stats")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Hit("for")
+	s.Hit("for")
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := s.Get("for"); u.Hits != 2 || u.Last.IsZero() {
+		t.Errorf("unexpected usage: %v", u)
+	}
+}
+
+func TestSincePersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snippets.stats")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := s.Since()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("the file should be created on open: %s", err)
+	}
+	time.Sleep(time.Millisecond)
+	s, _ = Open(path)
+	if !s.Since().Equal(since) {
+		t.Errorf("want: %s; has: %s", since, s.Since())
+	}
+}
+
+func TestOpenMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snippets.stats")
+	os.WriteFile(path, []byte("["), 0644)
+	if _, err := Open(path); err == nil {
+		t.Error("expected an error caused by malformed statistics")
+	}
+}
+
+func TestAddSeen(t *testing.T) {
+	s, _ := Open("")
+	now := s.Since().Add(time.Hour)
+	s.now = func() time.Time { return now }
+	if has := s.Seen("new"); !has.Equal(s.Since()) {
+		t.Errorf("want: %s; has: %s", s.Since(), has)
+	}
+	s.Hit("new")
+	s.Add("new")
+	if has := s.Seen("new"); !has.Equal(now) {
+		t.Errorf("want: %s; has: %s", now, has)
+	}
+	if u := s.Get("new"); u.Hits != 1 {
+		t.Errorf("usage should be kept: %v", u)
+	}
+}
+
+func TestRename(t *testing.T) {
+	s, _ := Open("")
+	s.Hit("old")
+	s.Rename("old", "new")
+	if u := s.Get("new"); u.Hits != 1 {
+		t.Errorf("usage should move to the new name: %v", u)
+	}
+	if u := s.Get("old"); u.Hits != 0 {
+		t.Errorf("usage should leave the old name: %v", u)
+	}
+	s.Rename("missing", "new")
+	if u := s.Get("new"); u.Hits != 1 {
+		t.Errorf("renaming an unknown snippet should keep usage: %v", u)
+	}
+}
@@ -1,11 +1,28 @@
 package stream
 
-// Opcode is used to specify allowed server operations.
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Protocol is the version of the protocol spoken by the client and the
+// server. It changes whenever requests or replies change incompatibly.
+// Version 2 sends opcodes by name and opens connections with Hello.
+const Protocol = 2
+
+// Opcode is used to specify allowed server operations. Opcodes travel by name,
+// so their numbers are internal to the build; they are still fixed because
+// clients older than protocol version 2 send them as numbers.
 type Opcode uint8
 
 // Result represents the result of the attempted operation.
 type result uint8
 
+// Code classifies the reason why the operation failed.
+type Code uint8
+
 const (
 	// Undefined represents an undefined operation.
 	Undefined Opcode = iota
@@ -19,6 +36,43 @@ const (
 	Delete
 	// Reload represents the directive to reload the snippet container.
 	Reload
+	// Update represents the operation of inserting or replacing snippets.
+	Update
+	// Dump represents the directive to write out all snippets in the source
+	// file syntax.
+	Dump
+	// Undo represents the directive to revert the most recent mutation.
+	Undo
+	// Redo represents the directive to reapply the most recently undone
+	// mutation.
+	Redo
+	// History represents the directive to list the journal of mutations.
+	History
+	// Backups represents the directive to list backups of the source file.
+	Backups
+	// Restore represents the directive to restore the source file from a
+	// backup.
+	Restore
+	// Log represents the directive to list commits changing a snippet.
+	Log
+	// Show represents the directive to write out a snippet at a given commit.
+	Show
+	// Usage represents the directive to list usage statistics of snippets.
+	Usage
+	// Unused represents the directive to list snippets not used within a
+	// time window.
+	Unused
+	// Rename represents the operation of giving a snippet a new name.
+	Rename
+	// Shutdown represents the directive to stop the server once the requests
+	// in progress are served.
+	Shutdown
+	// Stats represents the directive to write out the metrics of the server.
+	Stats
+	// Ping represents the directive to check that the server is alive.
+	Ping
+	// Version represents the directive to describe the running server.
+	Version
 )
 
 const (
@@ -28,14 +82,203 @@ const (
 	Failure
 )
 
-// Request defines the data format for the server request.
+const (
+	// OK means that the operation did not fail.
+	OK Code = iota
+	// Unknown means that the failure could not be classified.
+	Unknown
+	// Unsupported means that the server does not support the operation.
+	Unsupported
+	// NotFound means that the requested snippet does not exist.
+	NotFound
+	// Exists means that the snippet already exists.
+	Exists
+	// Invalid means that the request carried malformed or invalid snippets.
+	Invalid
+	// Conflict means that the source file is locked or was modified by
+	// another process, or that the snippet revision is stale.
+	Conflict
+)
+
+var opNames = map[Opcode]string{
+	Undefined: "undefined",
+	Find:      "find",
+	List:      "list",
+	Insert:    "insert",
+	Delete:    "delete",
+	Reload:    "reload",
+	Update:    "update",
+	Dump:      "dump",
+	Undo:      "undo",
+	Redo:      "redo",
+	History:   "history",
+	Backups:   "backups",
+	Restore:   "restore",
+	Log:       "log",
+	Show:      "show",
+	Usage:     "usage",
+	Unused:    "unused",
+	Rename:    "rename",
+	Shutdown:  "shutdown",
+	Stats:     "stats",
+	Ping:      "ping",
+	Version:   "version",
+}
+
+func (o Opcode) String() string {
+	if n, ok := opNames[o]; ok {
+		return n
+	}
+	return fmt.Sprintf("opcode(%d)", o)
+}
+
+// MarshalJSON encodes the opcode as its name.
+func (o Opcode) MarshalJSON() ([]byte, error) {
+	if n, ok := opNames[o]; ok {
+		return json.Marshal(n)
+	}
+	return json.Marshal(uint8(o))
+}
+
+// UnmarshalJSON decodes the opcode from its name or, as sent by clients older
+// than protocol version 2, from its number.
+func (o *Opcode) UnmarshalJSON(data []byte) error {
+	var n uint8
+	if err := json.Unmarshal(data, &n); err == nil {
+		*o = Opcode(n)
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	for op, opName := range opNames {
+		if opName == name && op != Undefined {
+			*o = op
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown operation %q", name)
+}
+
+// Operations lists the defined opcodes in order, leaving out Undefined.
+func Operations() []Opcode {
+	var ops []Opcode
+	for op := range opNames {
+		if op != Undefined {
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	return ops
+}
+
+var codeNames = map[Code]string{
+	OK:          "ok",
+	Unknown:     "unknown",
+	Unsupported: "unsupported",
+	NotFound:    "not found",
+	Exists:      "exists",
+	Invalid:     "invalid",
+	Conflict:    "conflict",
+}
+
+func (c Code) String() string {
+	if n, ok := codeNames[c]; ok {
+		return n
+	}
+	return codeNames[Unknown]
+}
+
+// Error is the failure reported back by the server.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Request defines the data format for the server request. User names the
+// client user on whose behalf the request is made. Rev is the revision of
+// the snippet the client expects to update or delete; an empty Rev skips the
+// check. Protocol is the protocol version of the client; it is zero for
+// clients older than protocol version 2.
 type Request struct {
+	Protocol  int    `json:"protocol,omitempty"`
 	Operation Opcode `json:"operation"`
 	Body      []byte `json:"body"`
+	User      string `json:"user,omitempty"`
+	Rev       string `json:"rev,omitempty"`
 }
 
-// Reply defines the data format for ther server reply.
+// Reply defines the data format for ther server reply. A failed reply carries
+// the error message in the body. Rev is the revision of the snippet found.
+// Protocol is the protocol version of the server; it is zero for servers
+// older than the Version opcode.
 type Reply struct {
-	Result result `json:"result"`
-	Body   []byte `json:"body"`
+	Result   result `json:"result"`
+	Body     []byte `json:"body"`
+	Code     Code   `json:"code"`
+	Rev      string `json:"rev,omitempty"`
+	Protocol int    `json:"protocol,omitempty"`
+}
+
+// Hello opens a connection: the client sends it before its first request to
+// learn what the server supports. Version is the build version of the client.
+type Hello struct {
+	Protocol int    `json:"protocol"`
+	Version  string `json:"version"`
+}
+
+// Welcome answers Hello with the protocol and build versions of the server
+// and its capabilities: the names of the operations it supports and of the
+// features it offers.
+type Welcome struct {
+	Protocol     int      `json:"protocol"`
+	Version      string   `json:"version"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// Features a server offers next to the operations it supports.
+const (
+	// FeatureBatch marks inserting, updating and deleting several snippets
+	// in one request that either succeeds or changes nothing.
+	FeatureBatch = "batch"
+	// FeatureAliases marks finding, deleting and renaming snippets by alias.
+	FeatureAliases = "aliases"
+	// FeatureRevisions marks rejecting changes to snippets whose revision is
+	// not the one the client expects.
+	FeatureRevisions = "revisions"
+)
+
+// Supports reports whether the server supports the operation.
+func (w Welcome) Supports(op Opcode) bool {
+	for _, c := range w.Capabilities {
+		if c == op.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// Status describes the running server. It is sent as JSON in the body of the
+// reply to the Version request. ReloadError is the error of the last reload,
+// if it failed.
+type Status struct {
+	Version     string        `json:"version"`
+	Protocol    int           `json:"protocol"`
+	Uptime      time.Duration `json:"uptime"`
+	Files       []string      `json:"files"`
+	Snippets    int           `json:"snippets"`
+	Reloaded    time.Time     `json:"reloaded"`
+	ReloadError string        `json:"reload_error,omitempty"`
+}
+
+// Err returns the *Error reported in the reply or nil if the operation
+// succeeded.
+func (r Reply) Err() error {
+	if r.Result != Failure {
+		return nil
+	}
+	return &Error{Code: r.Code, Message: string(r.Body)}
 }
@@ -1,6 +1,11 @@
 package stream
 
-import "testing"
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
 
 // Test if instances of Reply are created correctly.
 func TestReplyCreation(t *testing.T) {
@@ -14,7 +19,7 @@ func TestReplyCreation(t *testing.T) {
 	}
 	for _, d := range data {
 		t.Run(d.name, func(t *testing.T) {
-			_ = Reply{d.res, d.body}
+			_ = Reply{Result: d.res, Body: d.body}
 		})
 	}
 }
@@ -32,10 +37,92 @@ func TestRequestCreation(t *testing.T) {
 		{"failure", Insert, []byte("")},
 		{"failure", Delete, []byte("")},
 		{"failure", Reload, []byte("")},
+		{"failure", Update, []byte("")},
+		{"failure", Dump, []byte("")},
+		{"failure", Undo, []byte("")},
+		{"failure", Redo, []byte("")},
+		{"failure", History, []byte("")},
+		{"failure", Backups, []byte("")},
+		{"failure", Restore, []byte("")},
+		{"failure", Log, []byte("")},
+		{"failure", Show, []byte("")},
+		{"failure", Usage, []byte("")},
+		{"failure", Unused, []byte("")},
+		{"failure", Rename, []byte("")},
+		{"failure", Shutdown, []byte("")},
+		{"failure", Stats, []byte("")},
+		{"failure", Ping, []byte("")},
+		{"failure", Version, []byte("")},
 	}
 	for _, d := range data {
 		t.Run(d.name, func(t *testing.T) {
-			_ = Request{d.opcd, d.body}
+			_ = Request{Operation: d.opcd, Body: d.body}
 		})
 	}
 }
+
+// Verify that failed replies turn into errors carrying their code.
+func TestReplyErr(t *testing.T) {
+	if err := (Reply{Result: Success}).Err(); err != nil {
+		t.Errorf("want: %v; has: %v", nil, err)
+	}
+	rp := Reply{Result: Failure, Body: []byte("snippet was not found"), Code: NotFound}
+	err := rp.Err()
+	var e *Error
+	if !errors.As(err, &e) || e.Code != NotFound || err.Error() != string(rp.Body) {
+		t.Errorf("want: %s (%s); has: %v", rp.Body, NotFound, err)
+	}
+}
+
+func TestOpcodeString(t *testing.T) {
+	for op := Undefined; op <= Version; op++ {
+		if n := op.String(); n == "" || strings.HasPrefix(n, "opcode(") {
+			t.Errorf("opcode %d has no name", op)
+		}
+	}
+	if has := Opcode(255).String(); has != "opcode(255)" {
+		t.Errorf("want: opcode(255); has: %s", has)
+	}
+}
+
+// Opcodes travel by name, but numbers sent by older clients are still read.
+func TestOpcodeJSON(t *testing.T) {
+	b, err := json.Marshal(Request{Operation: Rename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"operation":"rename"`) {
+		t.Errorf("has: %s", b)
+	}
+	var rq Request
+	if err := json.Unmarshal(b, &rq); err != nil || rq.Operation != Rename {
+		t.Errorf("want: %s; has: %s (%v)", Rename, rq.Operation, err)
+	}
+	if err := json.Unmarshal([]byte(`{"operation":5}`), &rq); err != nil || rq.Operation != Reload {
+		t.Errorf("want: %s; has: %s (%v)", Reload, rq.Operation, err)
+	}
+	for _, src := range []string{`{"operation":"search"}`, `{"operation":"undefined"}`} {
+		if err := json.Unmarshal([]byte(src), &rq); err == nil {
+			t.Errorf("%s: want an error", src)
+		}
+	}
+}
+
+func TestWelcomeSupports(t *testing.T) {
+	var caps []string
+	for _, op := range Operations() {
+		caps = append(caps, op.String())
+	}
+	w := Welcome{Protocol: Protocol, Capabilities: append(caps, FeatureBatch)}
+	for _, op := range Operations() {
+		if !w.Supports(op) {
+			t.Errorf("%s is not supported", op)
+		}
+	}
+	if w.Supports(Undefined) || w.Supports(Opcode(255)) {
+		t.Error("undefined opcodes are supported")
+	}
+	if (Welcome{Capabilities: []string{"find"}}).Supports(Rename) {
+		t.Errorf("%s is supported", Rename)
+	}
+}
@@ -0,0 +1,157 @@
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoGit is raised when the git executable cannot be found.
+	ErrNoGit = errors.New("git executable not found")
+	// ErrBadRev is raised when the revision is malformed or does not exist.
+	ErrBadRev = errors.New("no such revision")
+)
+
+// Commit describes a single commit touching the source file.
+type Commit struct {
+	Rev     string
+	Author  string
+	Time    time.Time
+	Subject string
+}
+
+// Repo runs git against the local repository holding the source file.
+type Repo struct {
+	root string
+	path string
+}
+
+// Open opens the git repository containing the source file fname. When the
+// directory of the file is not in a repository yet, a new one is created
+// there.
+func Open(fname string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrNoGit
+	}
+	abs, err := filepath.Abs(fname)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(abs)
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		if _, err := git(dir, "init", "--quiet"); err != nil {
+			return nil, err
+		}
+		if root, err = git(dir, "rev-parse", "--show-toplevel"); err != nil {
+			return nil, err
+		}
+	}
+	root = strings.TrimSpace(root)
+	// NOTE: Resolve symbolic links so that the path is relative to the root
+	// reported by git, which has them resolved already.
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	rel, err := filepath.Rel(root, filepath.Join(dir, filepath.Base(abs)))
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{root: root, path: filepath.ToSlash(rel)}, nil
+}
+
+// Commit commits the current state of the source file with the message. It
+// does nothing if the file has not changed since the last commit.
+func (r *Repo) Commit(msg string) error {
+	if _, err := git(r.root, "add", "--", r.path); err != nil {
+		return err
+	}
+	if _, err := git(r.root, "diff", "--cached", "--quiet", "--", r.path); err == nil {
+		return nil
+	}
+	cmd := command(r.root, "commit", "--quiet", "--message", msg, "--", r.path)
+	// NOTE: Fall back to a generic identity so that commits do not fail on
+	// machines where git has not been configured
+	if name, _ := git(r.root, "config", "user.name"); strings.TrimSpace(name) == "" {
+		cmd.Env = append(
+			os.Environ(),
+			"GIT_AUTHOR_NAME=gsnipd",
+			"GIT_AUTHOR_EMAIL=gsnipd@localhost",
+			"GIT_COMMITTER_NAME=gsnipd",
+			"GIT_COMMITTER_EMAIL=gsnipd@localhost",
+		)
+	}
+	_, err := run(cmd)
+	return err
+}
+
+// Log lists commits touching the source file starting from the most recent
+// one.
+func (r *Repo) Log() ([]Commit, error) {
+	out, err := git(r.root, "log", "--format=%h%x09%an%x09%aI%x09%s", "--", r.path)
+	if err != nil {
+		// NOTE: A repository without commits has no log to show
+		if _, headErr := git(r.root, "rev-parse", "--verify", "--quiet", "HEAD"); headErr != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var result []Commit
+	for _, l := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.SplitN(l, "\t", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		t, _ := time.Parse(time.RFC3339, fields[2])
+		result = append(result, Commit{
+			Rev:     fields[0],
+			Author:  fields[1],
+			Time:    t,
+			Subject: fields[3],
+		})
+	}
+	return result, nil
+}
+
+// Show returns the contents of the source file at the revision.
+func (r *Repo) Show(rev string) ([]byte, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") || strings.ContainsAny(rev, ": \t\n") {
+		return nil, fmt.Errorf("%w: %s", ErrBadRev, rev)
+	}
+	out, err := git(r.root, "show", rev+":"+r.path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadRev, rev)
+	}
+	return []byte(out), nil
+}
+
+// git runs the git command in the directory and returns its standard output.
+func git(dir string, args ...string) (string, error) {
+	return run(command(dir, args...))
+}
+
+func command(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd
+}
+
+func run(cmd *exec.Cmd) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", cmd.Args[1], err)
+		}
+		return "", fmt.Errorf("git %s: %s", cmd.Args[1], msg)
+	}
+	return stdout.String(), nil
+}
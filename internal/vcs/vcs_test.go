@@ -0,0 +1,79 @@
+package vcs
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func repo(t *testing.T) (*Repo, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	fname := filepath.Join(t.TempDir(), "snippets")
+	if err := os.WriteFile(fname, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, fname
+}
+
+func TestCommitAndShow(t *testing.T) {
+	r, fname := repo(t)
+	if err := r.Commit("gsnip: insert one"); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(fname, []byte("second\n"), 0644)
+	if err := r.Commit("gsnip: update one"); err != nil {
+		t.Fatal(err)
+	}
+	commits, err := r.Log()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "gsnip: update one" {
+		t.Fatalf("unexpected log: %v", commits)
+	}
+	data, err := r.Show(commits[1].Rev)
+	if err != nil || string(data) != "first\n" {
+		t.Errorf("has: %q (%v)", data, err)
+	}
+	data, err = r.Show("HEAD")
+	if err != nil || string(data) != "second\n" {
+		t.Errorf("has: %q (%v)", data, err)
+	}
+}
+
+func TestCommitUnchanged(t *testing.T) {
+	r, _ := repo(t)
+	r.Commit("gsnip: insert one")
+	if err := r.Commit("gsnip: nothing"); err != nil {
+		t.Fatal(err)
+	}
+	if commits, _ := r.Log(); len(commits) != 1 {
+		t.Errorf("want a single commit; has: %v", commits)
+	}
+}
+
+func TestLogEmpty(t *testing.T) {
+	r, _ := repo(t)
+	if commits, err := r.Log(); err != nil || len(commits) != 0 {
+		t.Errorf("has: %v (%v)", commits, err)
+	}
+}
+
+func TestShowFails(t *testing.T) {
+	r, _ := repo(t)
+	r.Commit("gsnip: insert one")
+	for _, rev := range []string{"", "--output=/tmp/x", "HEAD:other", "deadbeef"} {
+		if _, err := r.Show(rev); !errors.Is(err, ErrBadRev) {
+			t.Errorf("%q: want: %v; has: %v", rev, ErrBadRev, err)
+		}
+	}
+}
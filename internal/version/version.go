@@ -0,0 +1,19 @@
+package version
+
+import "runtime/debug"
+
+// Version is the build version. It can be set at link time with
+// -ldflags "-X github.com/mdm-code/gsnip/internal/version.Version=v1.2.3".
+var Version = ""
+
+// String returns the build version: the one set at link time, the module
+// version recorded by go install, or devel for local builds.
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+	return "devel"
+}